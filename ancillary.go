@@ -18,3 +18,11 @@ func intMin(x, y int) int {
 	}
 	return x
 }
+
+// intMax - return the largest integer
+func intMax(x, y int) int {
+	if x < y {
+		return y
+	}
+	return x
+}
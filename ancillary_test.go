@@ -28,6 +28,26 @@ func TestIntMin(t *testing.T) {
 
 }
 
+func TestIntMax(t *testing.T) {
+	t.Parallel()
+	testTable := []struct{ a, b, expected int }{
+		{0, 0, 0},
+		{1, 2, 2},
+		{2, 1, 2},
+		{-1, 1, 1},
+		{-2, -1, -1},
+	}
+	for _, item := range testTable {
+		t.Run(fmt.Sprintf("%+v", item), func(t *testing.T) {
+			actual := intMax(item.a, item.b)
+			if item.expected != actual {
+				t.Logf("failed intMax(%d, %d) expected %d but got %d", item.a, item.b, item.expected, actual)
+				t.Fail()
+			}
+		})
+	}
+}
+
 func TestStringTruncate(t *testing.T) {
 	t.Parallel()
 	testTable := []struct {
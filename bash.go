@@ -1,10 +1,32 @@
 package dianella
 
 import (
+	"bytes"
+	"io"
 	"os"
-	"os/exec"
 )
 
+// stderrWriter - os.Stderr with secrets masked. Setting the "stderr_tail" variable to a number of
+// bytes also keeps the tail of the output for the step history and reports, otherwise os.Stderr is
+// passed straight to the command so it keeps the terminal. The returned function must be called
+// when the command finishes.
+func (s *Step) stderrWriter() (io.Writer, func()) {
+	size, _ := GetIntBinding(s.GetVar(), "stderr_tail", 0)
+	if size <= 0 {
+		w, flush := s.redacting(os.Stderr)
+		return w, func() {
+			flush()
+			s.lastStderr = ""
+		}
+	}
+	tail := &tailBuffer{max: size}
+	w, flush := s.redacting(io.MultiWriter(os.Stderr, tail))
+	return w, func() {
+		flush()
+		s.lastStderr = string(tail.data)
+	}
+}
+
 func (s *Step) Bash(cmd string) Stepper {
 	if s.Self.IsFailed() {
 		return s
@@ -19,13 +41,20 @@ func (s *Step) Bash(cmd string) Stepper {
 	if cmd != ex {
 		s.Self.Before("Bash.cmd", ex)
 	}
-	c := exec.Command("/bin/bash", "-c", ex)
-	c.Stderr = os.Stderr
-	c.Stdout = os.Stdout
-	err = c.Run()
+	s.spanAttribute("dianella.command", s.Self.Redact(ex))
+	if s.dryRunSkip("bash -c %s", ex) {
+		return s
+	}
+	stderr, flushStderr := s.stderrWriter()
+	stdout, flushStdout := s.redacting(os.Stdout)
+	err = s.Self.GetRunner().Run(Command{Line: ex, Stdout: stdout, Stderr: stderr})
+	flushStdout()
+	flushStderr()
 	if err != nil {
 		s.Self.FailErr(err)
+		return s
 	}
+	s.spanAttribute("process.exit_code", 0)
 	return s
 }
 func (s *Step) Sbash(cmd string) (result string, rs Stepper) {
@@ -39,11 +68,18 @@ func (s *Step) Sbash(cmd string) (result string, rs Stepper) {
 		s.Self.FailErr(err)
 		return "", s
 	}
-	c := exec.Command("/bin/bash", "-c", ex)
-	c.Stderr = os.Stderr
-	stdoutBytes, err := c.Output()
+	s.spanAttribute("dianella.command", s.Self.Redact(ex))
+	if s.dryRunSkip("bash -c %s", ex) {
+		return s.dryRunOutput, s
+	}
+	var stdout bytes.Buffer
+	stderr, flushStderr := s.stderrWriter()
+	err = s.Self.GetRunner().Run(Command{Line: ex, Stdout: &stdout, Stderr: stderr})
+	flushStderr()
 	if err != nil {
 		s.Self.FailErr(err)
+		return stdout.String(), s
 	}
-	return string(stdoutBytes), s
+	s.spanAttribute("process.exit_code", 0)
+	return stdout.String(), s
 }
@@ -2,6 +2,7 @@ package dianella
 
 import (
 	"testing"
+	"time"
 )
 
 func TestBashFailures(t *testing.T) {
@@ -103,3 +104,21 @@ func runBash(t *testing.T, testTable map[string]string, pass bool) {
 		})
 	}
 }
+
+func TestBashBackground(t *testing.T) {
+	t.Parallel()
+
+	for _, tail := range []int{4096, 0} {
+		s := BEGIN(t.Name()).ContinueOnError(true)
+		s.Set("trace", false).Set("stderr_tail", tail)
+		start := time.Now()
+		s.Bash("sleep 2 >/dev/null &")
+		out, _ := s.Sbash("sleep 2 & echo started")
+		if s.IsFailed() || out != "started\n" {
+			t.Errorf("unexpected failure %v, output '%s'", s.GetErr(), out)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected background commands not to be waited for with stderr_tail %d, took %s", tail, elapsed)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package dianella
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// CassetteEntry - one recorded command execution
+type CassetteEntry struct {
+	Command  string            `json:"command"`
+	Env      map[string]string `json:"env,omitempty"`
+	Dir      string            `json:"dir"`
+	Stdout   string            `json:"stdout"`
+	Stderr   string            `json:"stderr"`
+	ExitCode int               `json:"exit_code"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// Cassette - the recorded command executions of a run, in order
+type Cassette struct {
+	Entries []CassetteEntry `json:"entries"`
+}
+
+// ReadCassette - read a cassette written by a RecordingRunner
+func ReadCassette(filename string) (*Cassette, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &c, nil
+}
+
+// WriteFile - write the cassette as JSON, with an empty list of entries if there are none
+func (c *Cassette) WriteFile(filename string) error {
+	out := *c
+	if out.Entries == nil {
+		out.Entries = []CassetteEntry{}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0600)
+}
+
+// RecordingRunner - a Runner passing commands to another Runner and recording them, with their
+// output and exit code, to a cassette file written by Close. END closes the Step's Runner.
+type RecordingRunner struct {
+	Runner   Runner
+	Filename string
+	EnvNames []string            // the environment variables to record with each command
+	Redact   func(string) string // applied to the recorded command, environment and output, may be nil
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// Run - run the command with the wrapped Runner and record it
+func (r *RecordingRunner) Run(cmd Command) error {
+	var stdout, stderr bytes.Buffer
+	err := r.Runner.Run(Command{
+		Line:   cmd.Line,
+		Stdout: io.MultiWriter(writerOrDiscard(cmd.Stdout), &stdout),
+		Stderr: io.MultiWriter(writerOrDiscard(cmd.Stderr), &stderr),
+	})
+	redact := r.Redact
+	if redact == nil {
+		redact = func(text string) string { return text }
+	}
+	entry := CassetteEntry{
+		Command:  redact(cmd.Line),
+		Dir:      runnerDir(r.Runner),
+		Stdout:   redact(stdout.String()),
+		Stderr:   redact(stderr.String()),
+		ExitCode: exitCode(err),
+	}
+	for _, name := range r.EnvNames {
+		if v, ok := os.LookupEnv(name); ok {
+			if entry.Env == nil {
+				entry.Env = map[string]string{}
+			}
+			entry.Env[name] = redact(v)
+		}
+	}
+	if err != nil && entry.ExitCode == 0 {
+		entry.Error = redact(err.Error())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Entries = append(r.cassette.Entries, entry)
+	return err
+}
+
+// Close - write the cassette file with the commands recorded so far
+func (r *RecordingRunner) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cassette.WriteFile(r.Filename)
+}
+
+// WorkingDir - the directory of the wrapped Runner
+func (r *RecordingRunner) WorkingDir() string { return runnerDir(r.Runner) }
+
+// writerOrDiscard - the writer, or io.Discard if it is nil
+func writerOrDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+// ReplayRunner - a Runner serving the entries of a cassette in order without running anything.
+// A command which is not the next one recorded fails.
+type ReplayRunner struct {
+	Redact   func(string) string // applied to commands before comparing them with the cassette, may be nil
+	Dir      string              // if set, commands recorded in another directory fail
+	mu       sync.Mutex
+	cassette *Cassette
+	next     int
+}
+
+// NewReplayRunner - replay the recorded commands of the cassette
+func NewReplayRunner(c *Cassette) *ReplayRunner {
+	return &ReplayRunner{cassette: c}
+}
+
+// Run - write the recorded output of the next entry and return its exit code
+func (r *ReplayRunner) Run(cmd Command) error {
+	r.mu.Lock()
+	if r.next >= len(r.cassette.Entries) {
+		r.mu.Unlock()
+		return fmt.Errorf("unexpected command '%s', the cassette has no more commands", cmd.Line)
+	}
+	entry := r.cassette.Entries[r.next]
+	line := cmd.Line
+	if r.Redact != nil {
+		line = r.Redact(line)
+	}
+	if entry.Command != line {
+		r.mu.Unlock()
+		return fmt.Errorf("unexpected command '%s', the cassette has '%s'", line, entry.Command)
+	}
+	if r.Dir != "" && entry.Dir != "" && entry.Dir != r.Dir {
+		r.mu.Unlock()
+		return fmt.Errorf("unexpected directory '%s' for '%s', the cassette has '%s'", r.Dir, line, entry.Dir)
+	}
+	r.next++
+	r.mu.Unlock()
+	_, _ = io.WriteString(writerOrDiscard(cmd.Stdout), entry.Stdout)
+	_, _ = io.WriteString(writerOrDiscard(cmd.Stderr), entry.Stderr)
+	if entry.ExitCode != 0 {
+		return &ExitError{Code: entry.ExitCode}
+	}
+	if entry.Error != "" {
+		return errors.New(entry.Error)
+	}
+	return nil
+}
+
+// Remaining - the recorded commands not yet replayed
+func (r *ReplayRunner) Remaining() []CassetteEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]CassetteEntry(nil), r.cassette.Entries[r.next:]...)
+}
+
+// Record - record the commands of Bash and Sbash, with the named environment variables, to the
+// cassette file, which is written at END. Secrets are masked in the cassette.
+func (s *Step) Record(filename string, envNames ...string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("Record", filename, envNames)
+	defer s.Self.After()
+	if s.dryRunSkip("record commands to %s", filename) {
+		return s
+	}
+	s.Self.SetRunner(&RecordingRunner{
+		Runner:   s.Self.GetRunner(),
+		Filename: filename,
+		EnvNames: envNames,
+		Redact:   s.Self.Redact,
+	})
+	return s
+}
+
+// Replay - serve the commands of Bash and Sbash from the cassette file instead of running them,
+// failing on a command which is not the next one recorded. Set the same secrets as the recording
+// so the masked commands match.
+func (s *Step) Replay(filename string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("Replay", filename)
+	defer s.Self.After()
+	c, err := ReadCassette(filename)
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	replay := NewReplayRunner(c)
+	replay.Redact = s.Self.Redact
+	s.Self.SetRunner(replay)
+	return s
+}
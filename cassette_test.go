@@ -0,0 +1,147 @@
+package dianella
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cassette := filepath.Join(dir, "cassette.json")
+	marker := filepath.Join(dir, "marker")
+
+	script := func(s Stepper) (string, Stepper) {
+		s.SetSecret("token", "s3cr3t").
+			Set("marker", marker).
+			Bash("touch {{.Var.marker}} && echo {{.Var.token}} >/dev/null")
+		out, s := s.Sbash("echo -n hello; echo -n oops >&2")
+		s.Bash("exit 4")
+		return out, s
+	}
+
+	var record Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	out, _ := script(record.SetRunner(BashRunner{Dir: dir}).Record(cassette, "PATH", "DIANELLA_NOT_SET"))
+	if out != "hello" || record.GetErr().Error() != "exit status 4" {
+		t.Fatalf("unexpected recording result '%s' %v", out, record.GetErr())
+	}
+	if _, err := os.Stat(cassette); err == nil {
+		t.Errorf("expected the cassette to be written on close")
+	}
+	err := record.GetRunner().(*RecordingRunner).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadCassette(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(cassette)
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Errorf("secret leaked into cassette %s", data)
+	}
+	if len(c.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %v", c.Entries)
+	}
+	second := c.Entries[1]
+	if second.Stdout != "hello" || second.Stderr != "oops" || second.Dir != dir ||
+		len(second.Env) != 1 || second.Env["PATH"] != os.Getenv("PATH") {
+		t.Errorf("unexpected entry %v", second)
+	}
+	if c.Entries[2].ExitCode != 4 {
+		t.Errorf("expected exit code 4, got %v", c.Entries[2])
+	}
+
+	err = os.Remove(marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replay Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	out, _ = script(replay.Replay(cassette))
+	if out != "hello" || replay.GetErr().Error() != "exit status 4" {
+		t.Errorf("unexpected replay result '%s' %v", out, replay.GetErr())
+	}
+	history := replay.History()
+	if history[len(history)-1].ExitCode != 4 || history[len(history)-1].Stderr != "" {
+		t.Errorf("unexpected replayed record %v", history[len(history)-1])
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Errorf("expected nothing to run on replay")
+	}
+}
+
+func TestReplayUnexpectedCommand(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		commands []string
+		expected string
+	}{
+		"different command": {[]string{"date", "rm -rf /"}, "unexpected command 'rm -rf /', the cassette has 'uptime'"},
+		"extra command":     {[]string{"date", "uptime", "date"}, "unexpected command 'date', the cassette has no more commands"},
+		"in order":          {[]string{"date", "uptime"}, ""},
+		"other directory":   {[]string{"cd /tmp", "date"}, "unexpected directory '/srv' for 'date', the cassette has '/tmp'"},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cassette := filepath.Join(t.TempDir(), "cassette.json")
+			c := Cassette{Entries: []CassetteEntry{{Command: "date"}, {Command: "uptime"}}}
+			if name == "other directory" {
+				c.Entries = []CassetteEntry{{Command: "cd /tmp", Dir: "/srv"}, {Command: "date", Dir: "/tmp"}}
+			}
+			err := c.WriteFile(cassette)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s Stepper = BEGIN(name).ContinueOnError(true)
+			s.Replay(cassette)
+			s.GetRunner().(*ReplayRunner).Dir = "/srv"
+			for _, cmd := range plot.commands {
+				s.Bash(cmd)
+			}
+			actual := ""
+			if s.GetErr() != nil {
+				actual = s.GetErr().Error()
+			}
+			if actual != plot.expected {
+				t.Errorf("expected '%s', got '%s'", plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRecordWrittenAtEND(t *testing.T) {
+	t.Parallel()
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	BEGIN(t.Name()).Set("trace", false).Record(cassette).Bash("true").END()
+	c, err := ReadCassette(cassette)
+	if err != nil || len(c.Entries) != 1 || c.Entries[0].Command != "true" {
+		t.Errorf("expected END to write the cassette, got %v %v", c, err)
+	}
+}
+
+func TestRecordDryRun(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cassette := filepath.Join(dir, "cassette.json")
+	BEGIN(t.Name()).Set("trace", false).Record(cassette).Bash("echo hello").END()
+	golden, _ := os.ReadFile(cassette)
+	BEGIN(t.Name()).Set("trace", false).DryRun(true).Record(cassette).Bash("echo other").END()
+	BEGIN(t.Name()).Set("trace", false).Record(cassette).DryRun(true).Bash("echo other").END()
+	actual, _ := os.ReadFile(cassette)
+	if string(actual) != string(golden) {
+		t.Errorf("expected dry-run to leave the cassette\n%s\ngot\n%s", golden, actual)
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	BEGIN(t.Name()).Set("trace", false).Record(empty).END()
+	actual, _ = os.ReadFile(empty)
+	if !strings.Contains(string(actual), `"entries": []`) {
+		t.Errorf("expected an empty list of entries, got %s", actual)
+	}
+}
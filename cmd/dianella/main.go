@@ -0,0 +1,8 @@
+// dianella - runs YAML or JSON pipelines of dianella steps
+package main
+
+import "github.com/birchb1024/dianella/pipeline"
+
+func main() {
+	pipeline.Main()
+}
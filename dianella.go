@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 
 	"os"
 )
@@ -16,43 +17,103 @@ type Stepper interface {
 	CONTINUE(string) Stepper
 	ContinueOnError(bool) Stepper
 	Call(func(Stepper) Stepper) Stepper
+	Delims(left, right string) Stepper
+	DryRun(on bool) Stepper
+	DryRunOutput(output string) Stepper
 	END() Stepper
+	EachCSVRow(filename string, f func(header []string, row map[string]string) error) Stepper
+	EachCSVRowWith(filename string, opts CSVOptions, f func(header []string, row map[string]string) error) Stepper
+	Exit(code int)
 	Expand(template string, outputFileName string) Stepper
 	Fail(msg string) Stepper
 	FailErr(e error)
 	GetArg() []string
+	GetDelims() (string, string)
 	GetDescription() string
 	GetErr() error
 	GetFlag() map[string]any
+	GetRunner() Runner
 	GetStatus() int
 	GetStringVar(name string) (string, Stepper)
 	GetVar() map[string]any
+	History() []StepRecord
 	Init(Stepper, string)
+	Inputs(globs ...string) Stepper
+	IsDryRun() bool
 	IsFailed() bool
+	IsSecret(name string) bool
+	LoadVars(path string) Stepper
+	LoadVarsWith(path string, opts VarsOptions) Stepper
+	Outputs(globs ...string) Stepper
 	ReadCSV(filename string) (Stepper, RowsOfFields)
+	ReadCSVWith(filename string, opts CSVOptions) (Stepper, RowsOfFields)
+	ReadJSON(filename string, variableName string) Stepper
+	ReadYAML(filename string, variableName string) Stepper
+	Record(filename string, envNames ...string) Stepper
+	Redact(text string) string
+	RegisterSecretProvider(scheme string, provider SecretProvider) Stepper
+	Replay(filename string) Stepper
+	Run(targets ...string) Stepper
 	Sbash(cmd string) (string, Stepper)
+	SaveVars(path string, names ...string) Stepper
+	SbashJSON(cmd string, variableName string) Stepper
+	SbashTable(cmd string, opts TableOptions) (RowsOfFields, Stepper)
 	Set(variableName string, value any) Stepper
+	SetFromSecret(variableName string, reference string) Stepper
 	SetLogger(l *log.Logger)
+	SetLogHandler(h slog.Handler) Stepper
+	SetRunner(r Runner) Stepper
+	SetSecret(variableName string, value any) Stepper
+	SetTracer(t Tracer) Stepper
 	Sexpand(cmd string) (string, Stepper)
+	Summary(slowest int) string
+	Target(name string, deps []string, body func(Stepper) Stepper) Stepper
+	TargetState(filename string) Stepper
+	Use(middleware ...StepMiddleware) Stepper
+	WithDelims(left, right string, f func(Stepper) Stepper) Stepper
+	WrapErrors(wrap func(error) error) Stepper
+	WriteCSV(filename string, rows RowsOfFields) Stepper
+	WriteJUnit(filename string) Stepper
+	WriteTAP(filename string) Stepper
 }
 
 // Step - Struct to hold status of execution steps and variables passed between steps.
 type Step struct {
-	Arg            []string
-	Flag           map[string]any
-	Var            map[string]any
-	description    string
-	err            error
-	Self           Stepper
-	status         int
-	logg           *log.Logger
-	continueOnFail bool
+	Arg             []string
+	Flag            map[string]any
+	Var             map[string]any
+	description     string
+	err             error
+	Self            Stepper
+	status          int
+	logg            *log.Logger
+	continueOnFail  bool
+	leftDelim       string
+	rightDelim      string
+	secrets         map[string]bool
+	secretProviders map[string]SecretProvider
+	secretCache     map[string]string
+	handler         slog.Handler
+	tracer          Tracer
+	middleware      []StepMiddleware
+	runner          Runner
+	dryRun          bool
+	dryRunOutput    string
+	errWrap         func(error) error
+	targets         []*target
+	targetState     string
+	calls           []traceCall
+	history         []StepRecord
+	lastStderr      string
 }
 
 func (s *Step) GetArg() []string        { return s.Arg }
 func (s *Step) GetVar() map[string]any  { return s.Var }
 func (s *Step) GetFlag() map[string]any { return s.Flag }
 
+// GetDelims - return the template action delimiters, empty strings mean the default "{{" and "}}"
+func (s *Step) GetDelims() (string, string) { return s.leftDelim, s.rightDelim }
+
 // GetDescription - return the current step description
 func (s *Step) GetDescription() string { return s.description }
 func (s *Step) GetErr() error          { return s.err }
@@ -62,8 +123,14 @@ func (s *Step) IsFailed() bool {
 }
 
 func (s *Step) SetLogger(l *log.Logger) { s.logg = l }
-func (s *Step) After()                  {}
+func (s *Step) After() {
+	s.endCall()
+}
 func (s *Step) Before(info ...any) {
+	s.beginCall(info)
+	if s.handler != nil {
+		return
+	}
 	v, ok := s.Var["trace"]
 	if !ok {
 		return
@@ -71,9 +138,9 @@ func (s *Step) Before(info ...any) {
 	if v != true {
 		return
 	}
-	longMessage := fmt.Sprintf("INFO: %-16v", info)
+	longMessage := s.Self.Redact(fmt.Sprintf("INFO: %-16v", info))
 	width, _ := GetIntBinding(s.GetVar(), "trace_length", 80)
-	s.logg.Printf(stringTruncate(longMessage, uint(width)))
+	s.logg.Print(stringTruncate(longMessage, uint(width)))
 }
 
 func (s *Step) Init(st Stepper, desc string) {
@@ -84,6 +151,7 @@ func (s *Step) Init(st Stepper, desc string) {
 	s.Flag = map[string]any{}
 	s.Arg = flag.Args()
 	s.Var["trace"] = true
+	s.dryRun = *dryRunFlag
 	flag.VisitAll(func(f *flag.Flag) { s.Flag[f.Name] = f.Value })
 
 }
@@ -93,6 +161,33 @@ func (s *Step) ContinueOnError(x bool) Stepper {
 	return s
 }
 
+// Delims - set the template action delimiters used by Set, Bash, Sbash, Expand and Sexpand.
+// Empty strings restore the default "{{" and "}}".
+func (s *Step) Delims(left, right string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("Delims", left, right)
+	defer s.Self.After()
+	s.leftDelim = left
+	s.rightDelim = right
+	return s
+}
+
+// WithDelims - call the function with the template delimiters temporarily set, restoring them afterwards
+func (s *Step) WithDelims(left, right string, f func(Stepper) Stepper) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("WithDelims", left, right)
+	defer s.Self.After()
+	oldLeft, oldRight := s.leftDelim, s.rightDelim
+	s.leftDelim, s.rightDelim = left, right
+	defer func() { s.leftDelim, s.rightDelim = oldLeft, oldRight }()
+	f(s.Self)
+	return s
+}
+
 func (s *Step) Set(name string, value any) Stepper {
 	if s.Self.IsFailed() {
 		return s
@@ -133,13 +228,27 @@ func (s *Step) GetStringVar(name string) (string, Stepper) {
 	return fmt.Sprintf("%s", dd), s
 }
 
+// WrapErrors - pass the errors of Fail and FailErr through wrap before they are traced and kept,
+// for example to add the position of a pipeline step. nil stops wrapping.
+func (s *Step) WrapErrors(wrap func(error) error) Stepper {
+	s.errWrap = wrap
+	return s
+}
+
 func (s *Step) FailErr(e error) {
+	if s.errWrap != nil {
+		e = s.errWrap(e)
+	}
 	s.Self.Before("FailErr", e)
 	defer s.Self.After()
 	s.err = e
 	s.status = 1
 	if !s.continueOnFail {
-		log.Fatalf("When %s FailErr: %#v", s.description, e)
+		s.endCalls()
+		s.printSummary()
+		s.closeRunner()
+		s.logg.Print(s.Self.Redact(fmt.Sprintf("When %s FailErr: %#v", s.description, e)))
+		s.Self.Exit(1)
 	}
 }
 func (s *Step) Fail(msg string) Stepper {
@@ -147,8 +256,15 @@ func (s *Step) Fail(msg string) Stepper {
 	defer s.Self.After()
 	s.status = 1
 	s.err = fmt.Errorf(msg)
+	if s.errWrap != nil {
+		s.err = s.errWrap(s.err)
+	}
 	if !s.continueOnFail {
-		log.Fatalf("When %s Fail: %s", s.description, msg)
+		s.endCalls()
+		s.printSummary()
+		s.closeRunner()
+		s.logg.Print(s.Self.Redact(fmt.Sprintf("When %s Fail: %s", s.description, msg)))
+		s.Self.Exit(1)
 	}
 	return s
 }
@@ -162,6 +278,7 @@ func BEGIN(desc string) *Step {
 		Flag:        map[string]any{},
 		Arg:         flag.Args(),
 		logg:        log.Default(),
+		dryRun:      *dryRunFlag,
 	}
 	s.Self = &s
 	flag.VisitAll(func(f *flag.Flag) { s.Flag[f.Name] = f.Value })
@@ -179,7 +296,7 @@ func (s *Step) AND(desc string) Stepper {
 }
 func (s *Step) CONTINUE(desc string) Stepper {
 	if s.Self.IsFailed() {
-		s.logg.Printf("INFO: CONTINUE ignoring '%s' failure with status %d, %s", s.Self.GetDescription(), s.Self.GetStatus(), s.Self.GetErr())
+		s.logg.Print(s.Self.Redact(fmt.Sprintf("INFO: CONTINUE ignoring '%s' failure with status %d, %s", s.Self.GetDescription(), s.Self.GetStatus(), s.Self.GetErr())))
 	}
 	s.Self.Before("CONTINUE", desc)
 	defer s.Self.After()
@@ -190,14 +307,23 @@ func (s *Step) CONTINUE(desc string) Stepper {
 }
 func (s *Step) END() Stepper {
 	if s.Self.IsFailed() {
-		s.logg.Printf("ERROR: END '%s' failed with status %d, %s", s.Self.GetDescription(), s.Self.GetStatus(), s.Self.GetErr())
-		os.Exit(1)
+		s.logg.Print(s.Self.Redact(fmt.Sprintf("ERROR: END '%s' failed with status %d, %s", s.Self.GetDescription(), s.Self.GetStatus(), s.Self.GetErr())))
+		s.printSummary()
+		s.closeRunner()
+		s.Self.Exit(1)
+		return s
 	}
 	s.Self.Before("End")
-	defer s.Self.After()
+	s.Self.After()
+	s.printSummary()
+	s.closeRunner()
 	return s
 }
 
+// Exit - end the program with the exit code, END calls this when a step has failed, as do Fail and
+// FailErr without ContinueOnError
+func (s *Step) Exit(code int) { os.Exit(code) }
+
 func (s *Step) Call(f func(s Stepper) Stepper) Stepper {
 	if s.Self.IsFailed() {
 		return s
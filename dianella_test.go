@@ -1,13 +1,18 @@
-package dianella
+package dianella_test
 
 import (
 	"bytes"
 	"flag"
+	"fmt"
 	"io"
 	"log"
+	"os"
 	"strings"
 	"testing"
 	"time"
+
+	. "github.com/birchb1024/dianella"
+	"github.com/birchb1024/dianella/dianellatest"
 )
 
 func TestBasicsPass(t *testing.T) {
@@ -23,39 +28,34 @@ func TestBasicsPass(t *testing.T) {
 	t.Parallel()
 	flag.Parse()
 	given := struct{ time string }{time.Now().String()}
-	var s Stepper = BEGIN("Basic example").ContinueOnError(true)
-	s.Init(s, "Init")
+	s := dianellatest.NewTestStep(t)
 	s.Set("trace", false).
 		Set("date", given.time).
 		Call(mock).
 		AND("bash date").
 		Bash("date").
 		Bash("echo {{.Var.date}}")
-	tmpFile, s := s.Sbash("mktemp")
-	s.Set("tmpFile", strings.TrimSpace(tmpFile)).
-		Expand("tmpFile: {{.Var.tmpFile}} - Date: {{.Var.date}}\n", strings.TrimSpace(tmpFile)).
-		Bash("cat {{.Var.tmpFile}}").
-		Bash("rm -f {{.Var.tmpFile}}").
+	tmpFile, _ := s.Sbash("mktemp")
+	tmpFile = strings.TrimSpace(tmpFile)
+	s.Set("tmpFile", tmpFile).
+		Expand("tmpFile: {{.Var.tmpFile}} - Date: {{.Var.date}}\n", tmpFile)
+	content, _ := s.Sbash("cat {{.Var.tmpFile}}")
+	s.Bash("rm -f {{.Var.tmpFile}}").
 		Bash("false").
 		CONTINUE("ignore failure which is expected").
 		END()
-	if s.IsFailed() {
-		t.Log(s.GetErr())
-		t.Fail()
+	if tmpFile == "" || content != "tmpFile: "+tmpFile+" - Date: "+given.time+"\n" {
+		t.Errorf("expected the mktemp file to round trip, got '%s' in '%s'", content, tmpFile)
 	}
-	actual, s := s.GetStringVar("date")
-	if actual != given.time {
-		t.Errorf("date variable discrepency, expected: '%v', got: '%v'", given.time, actual)
-	}
-
+	s.AssertVar("date", given.time)
+	s.AssertCommandRan("rm -f " + tmpFile)
 }
 
 func TestBasicsEarlyFail(t *testing.T) {
 	t.Parallel()
 	flag.Parse()
 	given := struct{ time string }{time.Now().String()}
-	var s Stepper = BEGIN("Basic example").ContinueOnError(true)
-	s.Init(s, "Init")
+	s := dianellatest.NewTestStep(t)
 	s.Set("trace", true).
 		Set("date", given.time).
 		Set("dummy", "template failure {{").
@@ -63,62 +63,49 @@ func TestBasicsEarlyFail(t *testing.T) {
 		AND("bash date").
 		Bash("date").
 		Bash("echo {{.Var.date}}")
-	tmpFile, s := s.Sbash("mktemp")
-	s, _ = s.ReadCSV("foo")
-	_, s = s.Sexpand("{{")
-	s.Set("tmpFile", strings.TrimSpace(tmpFile)).
-		Expand("tmpFile: {{.Var.tmpFile}} - Date: {{.Var.date}}\n", strings.TrimSpace(tmpFile)).
+	tmpFile, _ := s.Sbash("mktemp")
+	_, rows := s.ReadCSV("foo")
+	expanded, _ := s.Sexpand("{{.Var.date}}")
+	s.Set("tmpFile", "never set").
+		Expand("tmpFile: {{.Var.tmpFile}} - Date: {{.Var.date}}\n", s.Path("expanded")).
 		Bash("cat {{.Var.tmpFile}}").
-		Bash("rm -f {{.Var.tmpFile}}").
 		Bash("false")
 
-	if !s.IsFailed() {
-		t.Logf("should have failed")
-		t.Fail()
+	s.AssertFailedWith("unclosed action")
+	if tmpFile != "" || rows != nil || expanded != "" {
+		t.Errorf("expected steps after the failure to do nothing, got '%s' %v '%s'", tmpFile, rows, expanded)
 	}
-	_, s = s.GetStringVar("ZZZZ")
-	if !strings.Contains(s.GetErr().Error(), "missing 'ZZZZ' variable") {
-		t.Errorf("expected error with missing ZZZZ variable: got: '%s'", s.GetErr().Error())
+	if _, ok := s.GetVar()["tmpFile"]; ok {
+		t.Errorf("expected Set after the failure to do nothing")
 	}
-
+	if _, err := os.Stat(s.Path("expanded")); err == nil {
+		t.Errorf("expected Expand after the failure to write nothing")
+	}
+	if len(s.Commands()) != 0 {
+		t.Errorf("expected no commands after the failure, got %v", s.Commands())
+	}
+	_, _ = s.GetStringVar("ZZZZ")
+	s.AssertFailedWith("missing 'ZZZZ' variable")
 }
 
 func TestSexpandFails(t *testing.T) {
 	//t.Parallel()
 	flag.Parse()
 	given := struct{ time string }{time.Now().String()}
-	s := BEGIN("Bad template").ContinueOnError(true).
-		Set("date", given.time)
-	_, s = s.Sexpand("{{")
-	t.Log(s.GetErr())
-	if !s.IsFailed() {
-		t.Logf("should have failed")
-		t.Fail()
-	}
-	actual, s := s.GetStringVar("date")
-	if actual != given.time {
-		t.Errorf("date variable discrepency, expected: '%v', got: '%v'", given.time, actual)
-	}
-
+	s := dianellatest.NewTestStep(t)
+	s.AND("Bad template").Set("date", given.time).Sexpand("{{")
+	s.AssertFailedWith("unclosed action")
+	s.AssertVar("date", given.time)
 }
 
 func TestExpandFails(t *testing.T) {
 	t.Parallel()
 	flag.Parse()
 	given := struct{ time string }{time.Now().String()}
-	s := BEGIN("Bad template").ContinueOnError(true).
-		Set("date", given.time).
-		Expand("{{", "/tmp/foo")
-	t.Log(s.GetErr())
-	if !s.IsFailed() {
-		t.Logf("should have failed")
-		t.Fail()
-	}
-	actual, s := s.GetStringVar("date")
-	if actual != given.time {
-		t.Errorf("date variable discrepency, expected: '%v', got: '%v'", given.time, actual)
-	}
-
+	s := dianellatest.NewTestStep(t)
+	s.AND("Bad template").Set("date", given.time).Expand("{{", s.Path("foo"))
+	s.AssertFailedWith("unclosed action")
+	s.AssertVar("date", given.time)
 }
 
 func TestGetIntBinding(t *testing.T) {
@@ -137,7 +124,7 @@ func TestGetIntBinding(t *testing.T) {
 		"big":         {given: 424242, alt: 0, expect: 424242},
 	} {
 		t.Run(name, func(t *testing.T) {
-			s := BEGIN(t.Name()).ContinueOnError(true)
+			s := dianellatest.NewTestStep(t)
 			if plot.given != nil {
 				s.Set("trace-length", plot.given)
 			}
@@ -164,7 +151,7 @@ func TestTrace(t *testing.T) {
 		"huge":        {given: 2000, expect: "\nINFO: [Bash.cmd         echo '2000' 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 >/dev/null]\n"},
 	} {
 		t.Run(name, func(t *testing.T) {
-			s := BEGIN(t.Name()).ContinueOnError(true)
+			s := dianellatest.NewTestStep(t)
 			var b bytes.Buffer
 			lg := log.New(io.Writer(&b), "", 0)
 			s.SetLogger(lg)
@@ -181,3 +168,99 @@ func TestTrace(t *testing.T) {
 		})
 	}
 }
+
+func TestTracePercent(t *testing.T) {
+	t.Parallel()
+	s := dianellatest.NewTestStep(t)
+	var b bytes.Buffer
+	s.SetLogger(log.New(&b, "", 0))
+	s.Set("trace", true).Bash("date +%Y >/dev/null")
+	if !strings.Contains(b.String(), "date +%Y >/dev/null") || strings.Contains(b.String(), "MISSING") {
+		t.Errorf("expected the command traced as written, got '%s'", b.String())
+	}
+}
+
+func TestDelims(t *testing.T) {
+	t.Parallel()
+
+	for name, plot := range map[string]struct {
+		left, right string
+		given       string
+		expect      string
+	}{
+		"default":           {given: "{{.Var.name}}", expect: "world"},
+		"square":            {left: "[[", right: "]]", given: "[[.Var.name]] {{ .Values.x }}", expect: "world {{ .Values.x }}"},
+		"github actions":    {left: "<%", right: "%>", given: "${{ secrets.<%.Var.name%> }}", expect: "${{ secrets.world }}"},
+		"square not braces": {left: "[[", right: "]]", given: "{{.Var.name}}", expect: "{{.Var.name}}"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := dianellatest.NewTestStep(t)
+			actual, _ := s.Set("name", "world").
+				Delims(plot.left, plot.right).
+				Sexpand(plot.given)
+			if actual != plot.expect {
+				t.Errorf("plot was %v, but got '%s'", plot, actual)
+			}
+		})
+	}
+}
+
+func TestWithDelims(t *testing.T) {
+	t.Parallel()
+	var actual string
+	s := dianellatest.NewTestStep(t)
+	s.Set("name", "world").
+		WithDelims("[[", "]]", func(s Stepper) Stepper {
+			actual, s = s.Sexpand("[[.Var.name]] {{ x }}")
+			return s
+		})
+	if actual != "world {{ x }}" {
+		t.Errorf("expected 'world {{ x }}', got '%s'", actual)
+	}
+	left, right := s.GetDelims()
+	if left != "" || right != "" {
+		t.Errorf("expected default delimiters restored, got '%s' '%s'", left, right)
+	}
+	actual, _ = s.Sexpand("{{.Var.name}}")
+	if actual != "world" {
+		t.Errorf("expected 'world', got '%s'", actual)
+	}
+}
+
+func TestExpandoDelims(t *testing.T) {
+	t.Parallel()
+	s := dianellatest.NewTestStep(t)
+	s.Delims("[[", "]]").Set("name", "world")
+	actual, err := Expando("<<.Var.name>> [[.Var.name]]", s, "<<", ">>")
+	if err != nil {
+		t.Error(err)
+	}
+	if actual != "world [[.Var.name]]" {
+		t.Errorf("expected per-call delimiters to win, got '%s'", actual)
+	}
+	_, err = Expando("x", s, "<<")
+	if err == nil {
+		t.Errorf("expected error for unpaired delimiter")
+	}
+}
+
+func TestWrapErrors(t *testing.T) {
+	t.Parallel()
+	s := dianellatest.NewTestStep(t)
+	s.Set("trace", false).
+		WrapErrors(func(err error) error { return fmt.Errorf("deploy.yaml:3: %w", err) }).
+		Bash("exit 3")
+	s.AssertFailedWith("deploy.yaml:3: exit status 3")
+	s.CONTINUE("again").WrapErrors(nil).Fail("plain")
+	s.AssertFailedWith("plain")
+	var failures []string
+	for _, r := range s.History() {
+		if r.Error != "" {
+			failures = append(failures, r.Method+": "+r.Error)
+		}
+	}
+	expected := "FailErr: deploy.yaml:3: exit status 3,Bash: deploy.yaml:3: exit status 3,Fail: plain"
+	if strings.Join(failures, ",") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(failures, ","))
+	}
+}
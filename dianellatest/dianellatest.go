@@ -0,0 +1,201 @@
+// Package dianellatest - helpers for unit testing dianella scripts
+package dianellatest
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/birchb1024/dianella"
+)
+
+// TestStep - a Step for tests. It logs to t.Log, continues on error, never calls os.Exit and fails
+// the test at the end if a step failed unexpectedly. Commands run in Root, a temporary directory
+// which is also the "root" variable, and are recorded for AssertCommandRan.
+type TestStep struct {
+	dianella.Step
+	T    testing.TB
+	Root string
+
+	commands *commandLog
+	expected error // the failure checked by AssertFailedWith
+}
+
+// commandLog - the command lines run by the Runners of a TestStep
+type commandLog struct {
+	mu    sync.Mutex
+	lines []string
+	depth int
+}
+
+// loggedRunner - a Runner recording the command lines in the log before passing them on. Runners
+// wrapping a loggedRunner, such as a RecordingRunner, are wrapped again by GetRunner, so only the
+// outermost records each command.
+type loggedRunner struct {
+	log    *commandLog
+	runner dianella.Runner
+}
+
+func (r loggedRunner) Run(cmd dianella.Command) error {
+	r.log.mu.Lock()
+	if r.log.depth == 0 {
+		r.log.lines = append(r.log.lines, cmd.Line)
+	}
+	r.log.depth++
+	r.log.mu.Unlock()
+	defer func() {
+		r.log.mu.Lock()
+		r.log.depth--
+		r.log.mu.Unlock()
+	}()
+	return r.runner.Run(cmd)
+}
+
+// WorkingDir - the directory of the wrapped Runner
+func (r loggedRunner) WorkingDir() string {
+	if d, ok := r.runner.(interface{ WorkingDir() string }); ok {
+		return d.WorkingDir()
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// Close - close the wrapped Runner if it is an io.Closer, so END writes a recorded cassette
+func (r loggedRunner) Close() error {
+	if c, ok := r.runner.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// logWriter - passes log lines to t.Log
+type logWriter struct {
+	t testing.TB
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// NewTestStep - a TestStep described by the test name
+func NewTestStep(t testing.TB) *TestStep {
+	ts := &TestStep{T: t, Root: t.TempDir()}
+	ts.Init(ts, t.Name())
+	ts.ContinueOnError(true)
+	ts.SetLogger(log.New(logWriter{t}, "", 0))
+	ts.Var["root"] = ts.Root
+	ts.commands = &commandLog{}
+	ts.Step.SetRunner(dianella.BashRunner{Dir: ts.Root})
+	t.Cleanup(func() {
+		if ts.IsFailed() && !errors.Is(ts.GetErr(), ts.expected) {
+			t.Errorf("step '%s' failed unexpectedly with status %d: %v", ts.GetDescription(), ts.GetStatus(), ts.GetErr())
+		}
+	})
+	return ts
+}
+
+// SetRunner - run the commands with the runner, still recording them. nil restores the BashRunner
+// in Root.
+func (ts *TestStep) SetRunner(r dianella.Runner) dianella.Stepper {
+	if r == nil {
+		r = dianella.BashRunner{Dir: ts.Root}
+	}
+	return ts.Step.SetRunner(r)
+}
+
+// GetRunner - the runner executing the commands, recording them for Commands
+func (ts *TestStep) GetRunner() dianella.Runner {
+	return loggedRunner{log: ts.commands, runner: ts.Step.GetRunner()}
+}
+
+// Exit - log instead of exiting, END calls this when a step has failed, as do Fail and FailErr
+// without ContinueOnError. The test fails at the end unless AssertFailedWith expected the failure.
+func (ts *TestStep) Exit(code int) {
+	ts.T.Helper()
+	ts.T.Logf("END exit code %d", code)
+}
+
+// Commands - the command lines run by Bash and Sbash so far, in order
+func (ts *TestStep) Commands() []string {
+	ts.commands.mu.Lock()
+	defer ts.commands.mu.Unlock()
+	return append([]string(nil), ts.commands.lines...)
+}
+
+// Path - the path of a file in Root
+func (ts *TestStep) Path(name string) string {
+	return filepath.Join(ts.Root, name)
+}
+
+// WriteFile - create a file in Root, making its directories
+func (ts *TestStep) WriteFile(name string, content string) {
+	ts.T.Helper()
+	path := ts.Path(name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = os.WriteFile(path, []byte(content), 0644)
+	}
+	if err != nil {
+		ts.T.Fatal(err)
+	}
+}
+
+// AssertVar - check the variable has the expected value of the same type
+func (ts *TestStep) AssertVar(name string, expected any) {
+	ts.T.Helper()
+	actual, ok := ts.Var[name]
+	if !ok {
+		ts.T.Errorf("expected variable '%s' to be set", name)
+		return
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		ts.T.Errorf("expected variable '%s' to be %#v, got %#v", name, expected, actual)
+	}
+}
+
+// AssertFailedWith - check a step failed with an error containing the text. That failure is then
+// expected and does not fail the test, a later failure after CONTINUE still does.
+func (ts *TestStep) AssertFailedWith(text string) {
+	ts.T.Helper()
+	ts.expected = ts.GetErr()
+	if !ts.IsFailed() {
+		ts.T.Errorf("expected a failure with '%s', but no step failed", text)
+		return
+	}
+	if ts.GetErr() == nil || !strings.Contains(ts.GetErr().Error(), text) {
+		ts.T.Errorf("expected a failure with '%s', got %v", text, ts.GetErr())
+	}
+}
+
+// AssertCommandRan - check Bash or Sbash ran the exact command line, after template expansion
+func (ts *TestStep) AssertCommandRan(line string) {
+	ts.T.Helper()
+	commands := ts.Commands()
+	for _, c := range commands {
+		if c == line {
+			return
+		}
+	}
+	ts.T.Errorf("expected command '%s' to run, ran %q", line, commands)
+}
+
+// AssertFile - check the file in Root has the expected content
+func (ts *TestStep) AssertFile(name string, expected string) {
+	ts.T.Helper()
+	data, err := os.ReadFile(ts.Path(name))
+	if err != nil {
+		ts.T.Errorf("expected file '%s': %v", name, err)
+		return
+	}
+	if string(data) != expected {
+		ts.T.Errorf("expected file '%s' to contain '%s', got '%s'", name, expected, data)
+	}
+}
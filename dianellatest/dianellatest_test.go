@@ -0,0 +1,163 @@
+package dianellatest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/birchb1024/dianella"
+)
+
+// spyT - records the errors and cleanups of a test using a TestStep
+type spyT struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (s *spyT) Errorf(format string, args ...any) {
+	s.errors = append(s.errors, fmt.Sprintf(format, args...))
+}
+func (s *spyT) Cleanup(f func()) { s.cleanups = append(s.cleanups, f) }
+
+func (s *spyT) finish() []string {
+	for i := len(s.cleanups) - 1; i >= 0; i-- {
+		s.cleanups[i]()
+	}
+	return s.errors
+}
+
+func TestNewTestStep(t *testing.T) {
+	t.Parallel()
+	s := NewTestStep(t)
+	s.WriteFile("in/names.txt", "Hales\nButler\n")
+	s.Set("greeting", "hello {{.Var.root}}").
+		Bash("sort in/names.txt > sorted.txt").
+		Expand("{{.Var.greeting}}", s.Path("greeting.txt")).
+		END()
+
+	s.AssertVar("greeting", "hello "+s.Root)
+	s.AssertCommandRan("sort in/names.txt > sorted.txt")
+	s.AssertFile("sorted.txt", "Butler\nHales\n")
+	s.AssertFile("greeting.txt", "hello "+s.Root)
+}
+
+func TestAssertFailedWith(t *testing.T) {
+	t.Parallel()
+	s := NewTestStep(t)
+	s.SetRunner(dianella.NewFakeRunner().On("make deploy", dianella.FakeResponse{ExitCode: 2})).
+		Bash("make deploy").
+		Bash("never runs").
+		END()
+	s.AssertFailedWith("exit status 2")
+	if strings.Join(s.Commands(), ",") != "make deploy" {
+		t.Errorf("unexpected commands %v", s.Commands())
+	}
+}
+
+func TestUnexpectedFailure(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		script   func(s *TestStep)
+		expected []string
+	}{
+		"passes": {
+			func(s *TestStep) { s.Set("x", 1).END(); s.AssertVar("x", 1) },
+			nil,
+		},
+		"unexpected failure": {
+			func(s *TestStep) { s.Bash("exit 3").END() },
+			[]string{"step 'TestUnexpectedFailure' failed unexpectedly with status 1: exit status 3"},
+		},
+		"fail fast": {
+			func(s *TestStep) { s.ContinueOnError(false).Bash("exit 5").Bash("never runs") },
+			[]string{"step 'TestUnexpectedFailure' failed unexpectedly with status 1: exit status 5"},
+		},
+		"failure after an expected failure": {
+			func(s *TestStep) {
+				s.Bash("false")
+				s.AssertFailedWith("exit status 1")
+				s.CONTINUE("again").Bash("exit 7").END()
+			},
+			[]string{"step 'again' failed unexpectedly with status 1: exit status 7"},
+		},
+		"no failure": {
+			func(s *TestStep) { s.Bash("true"); s.AssertFailedWith("exit status") },
+			[]string{"expected a failure with 'exit status', but no step failed"},
+		},
+		"wrong assertions": {
+			func(s *TestStep) {
+				s.Set("x", "1").Set("n", 1)
+				s.AssertVar("x", "2")
+				s.AssertVar("n", "1")
+				s.AssertVar("y", "1")
+				s.AssertCommandRan("ls")
+				s.AssertFile("missing", "")
+			},
+			[]string{
+				`expected variable 'x' to be "2", got "1"`,
+				`expected variable 'n' to be "1", got 1`,
+				"expected variable 'y' to be set",
+				`expected command 'ls' to run, ran []`,
+				"expected file 'missing': open " + "ROOT/missing: no such file or directory",
+			},
+		},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			spy := &spyT{TB: t}
+			s := NewTestStep(spy)
+			s.AND(t.Name())
+			plot.script(s)
+			actual := strings.ReplaceAll(strings.Join(spy.finish(), "\n"), s.Root, "ROOT")
+			expected := strings.ReplaceAll(strings.Join(plot.expected, "\n"), "TestUnexpectedFailure", t.Name())
+			if actual != expected {
+				t.Errorf("expected\n%s\ngot\n%s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	t.Parallel()
+	s := NewTestStep(t)
+	s.ContinueOnError(false).
+		Fail("stop here").
+		Bash("never runs")
+	s.AssertFailedWith("stop here")
+	if len(s.Commands()) != 0 {
+		t.Errorf("expected no commands after a fail-fast failure, got %v", s.Commands())
+	}
+}
+
+func TestGetRunner(t *testing.T) {
+	t.Parallel()
+	s := NewTestStep(t)
+	fake := dianella.NewFakeRunner().On("date", dianella.FakeResponse{Stdout: "today"})
+	s.SetRunner(fake)
+	err := s.GetRunner().Run(dianella.Command{Line: "date"})
+	if err != nil || !fake.Ran("date") {
+		t.Errorf("expected GetRunner to run with the fake, got %v", err)
+	}
+	s.AssertCommandRan("date")
+}
+
+func TestRecordInTestStep(t *testing.T) {
+	t.Parallel()
+	s := NewTestStep(t)
+	s.Record(s.Path("cassette.json")).
+		Bash("echo recorded > out.txt").
+		END()
+	if strings.Join(s.Commands(), ",") != "echo recorded > out.txt" {
+		t.Errorf("expected the command recorded once, got %v", s.Commands())
+	}
+	s.AssertFile("out.txt", "recorded\n")
+	c, err := dianella.ReadCassette(s.Path("cassette.json"))
+	if err != nil || len(c.Entries) != 1 || c.Entries[0].Command != "echo recorded > out.txt" {
+		t.Errorf("unexpected cassette %v %v", c, err)
+	}
+}
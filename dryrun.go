@@ -0,0 +1,36 @@
+package dianella
+
+import (
+	"flag"
+	"fmt"
+)
+
+// dryRunFlag - the standard flag turning on DryRun for every Step
+var dryRunFlag = flag.Bool("dianella.dry-run", false, "log the commands and file writes of steps instead of doing them")
+
+// DryRun - when on, Bash, Sbash and the steps which write files log what they would do, with the
+// templates expanded, instead of doing it. Sbash returns the DryRunOutput text. Also turned on by
+// the -dianella.dry-run flag.
+func (s *Step) DryRun(on bool) Stepper {
+	s.dryRun = on
+	return s
+}
+
+// IsDryRun - true if steps should log instead of having side effects
+func (s *Step) IsDryRun() bool { return s.dryRun }
+
+// DryRunOutput - the placeholder output of Sbash in dry-run mode, so following steps have
+// something to work with, for example "[]" before SbashJSON
+func (s *Step) DryRunOutput(output string) Stepper {
+	s.dryRunOutput = output
+	return s
+}
+
+// dryRunSkip - in dry-run mode log what the step would do and return true
+func (s *Step) dryRunSkip(format string, args ...any) bool {
+	if !s.Self.IsDryRun() {
+		return false
+	}
+	s.logg.Printf("%s", s.Self.Redact("DRY-RUN: "+fmt.Sprintf(format, args...)))
+	return true
+}
@@ -0,0 +1,63 @@
+package dianella
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	var b bytes.Buffer
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s.SetLogger(log.New(&b, "", 0))
+	s.Set("trace", false).
+		Set("dir", dir).
+		SetSecret("token", "s3cr3t").
+		DryRun(true).
+		DryRunOutput(`{"replicas": 3}`).
+		Bash("touch {{.Var.dir}}/bash --token {{.Var.token}}").
+		SbashJSON("kubectl get deployment -o json", "deployment").
+		Expand("replicas={{query .Var.deployment \"replicas\"}}", filepath.Join(dir, "expanded")).
+		WriteCSV(filepath.Join(dir, "rows.csv"), RowsOfFields{{"a"}, {"1"}}).
+		SaveVars(filepath.Join(dir, "vars.env"), "dir").
+		WriteTAP(filepath.Join(dir, "report.tap"))
+	out, _ := s.Sbash("date")
+
+	if s.IsFailed() {
+		t.Fatalf("unexpected failure %v", s.GetErr())
+	}
+	if out != `{"replicas": 3}` {
+		t.Errorf("expected placeholder output, got '%s'", out)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("expected no side effects, found %v", entries)
+	}
+	expected := []string{
+		"DRY-RUN: bash -c touch " + dir + "/bash --token *****",
+		"DRY-RUN: bash -c kubectl get deployment -o json",
+		"DRY-RUN: write " + filepath.Join(dir, "expanded") + ":\nreplicas=3",
+		"DRY-RUN: write " + filepath.Join(dir, "rows.csv") + ":\na\n1",
+		"DRY-RUN: write " + filepath.Join(dir, "vars.env") + ":\ndir=\"" + dir + "\"",
+		"DRY-RUN: write TAP report " + filepath.Join(dir, "report.tap"),
+		"DRY-RUN: bash -c date",
+	}
+	for _, e := range expected {
+		if !strings.Contains(b.String(), e) {
+			t.Errorf("expected '%s' in '%s'", e, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "s3cr3t") {
+		t.Errorf("secret leaked into '%s'", b.String())
+	}
+
+	s.DryRun(false).Bash("touch {{.Var.dir}}/bash")
+	if _, err := os.Stat(filepath.Join(dir, "bash")); err != nil {
+		t.Errorf("expected command to run after DryRun(false): %v", err)
+	}
+}
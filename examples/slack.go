@@ -39,7 +39,7 @@ func (s *mySlackStep) SendSlack(blockFile string) dianella.Stepper {
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+fmt.Sprintf("%s", s.Flag["bearerToken"]))
+	req.Header.Set("Authorization", "Bearer "+fmt.Sprintf("%s", s.Var["bearerToken"]))
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -67,16 +67,17 @@ func (s *mySlackStep) SendSlack(blockFile string) dianella.Stepper {
 }
 
 var slackPostMessageURL string
-var slackBearerToken string
+var slackBearerTokenRef string
 var slackChannelName string
 
 func main() {
 	flag.StringVar(&slackPostMessageURL, "postMessageURL", "https://slack.com/api/chat.postMessage", "Slack API Bearer Token")
-	flag.StringVar(&slackBearerToken, "bearerToken", "", "Slack API Bearer Token")
+	flag.StringVar(&slackBearerTokenRef, "bearerTokenRef", "env://SLACK_BEARER_TOKEN", "Slack API Bearer Token reference, e.g. file:///run/secrets/slack")
 	flag.StringVar(&slackChannelName, "channelName", "test-slackbot-bill-birch", "SlackBot Channel Name")
 	flag.Parse()
 
 	s := BEGINslack("Start notifications")
+	s.SetFromSecret("bearerToken", slackBearerTokenRef)
 	slackReportTemplate, _ := s.Sbash("cat slackBlockTemplate.txt")
 	s.AND("Read the epoch date and time").
 		Set("date", time.Now().Unix()).
@@ -2,14 +2,33 @@ package dianella
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"text/template"
 )
 
+// templateFuncs - functions available in all templates in addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"query": Query,
+}
+
 // Expando - Use Go template module to interpolate expansions in a string
-// using data from the environment (the SICP sense of environment)
-func Expando(templateSource string, environment any) (string, error) {
-	temp, err := template.New("Expando").Parse(templateSource)
+// using data from the environment (the SICP sense of environment).
+// If the environment has a GetDelims() method its delimiters are used, an optional
+// left and right delimiter pair overrides them for this call only.
+func Expando(templateSource string, environment any, delims ...string) (string, error) {
+	var left, right string
+	if d, ok := environment.(interface{ GetDelims() (string, string) }); ok {
+		left, right = d.GetDelims()
+	}
+	switch len(delims) {
+	case 0:
+	case 2:
+		left, right = delims[0], delims[1]
+	default:
+		return "", fmt.Errorf("expected left and right delimiters, got %v", delims)
+	}
+	temp, err := template.New("Expando").Delims(left, right).Funcs(templateFuncs).Parse(templateSource)
 	if err != nil {
 		return "", err
 	}
@@ -34,6 +53,9 @@ func (s *Step) Expand(template string, filename string) Stepper {
 		s.FailErr(err)
 		return s
 	}
+	if s.dryRunSkip("write %s:\n%s", filename, expanded) {
+		return s
+	}
 	err = os.WriteFile(filename, []byte(expanded), 0644)
 	if err != nil {
 		s.FailErr(err)
@@ -1,3 +1,11 @@
 module github.com/birchb1024/dianella
 
-go 1.18
+go 1.21
+
+require (
+	github.com/BurntSushi/toml v1.5.0
+	golang.org/x/term v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.21.0 // indirect
@@ -0,0 +1,209 @@
+package dianella
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StepRecord - the outcome of one step method call, see History
+type StepRecord struct {
+	Description string        `json:"description"`
+	Method      string        `json:"method"`
+	Args        []string      `json:"args,omitempty"`
+	Start       time.Time     `json:"start"`
+	Duration    time.Duration `json:"duration"`
+	Status      int           `json:"status"`
+	Error       string        `json:"error,omitempty"`
+	ExitCode    int           `json:"exit_code,omitempty"`
+	Stderr      string        `json:"stderr,omitempty"`
+	Depth       int           `json:"depth"`
+}
+
+// traceCall - a step method in progress, between Before and After
+type traceCall struct {
+	method   string
+	args     []string
+	start    time.Time
+	span     Span
+	stepCall *StepCall
+	ends     []func()
+}
+
+// defaultHistoryLength - how many records History keeps when the "history_length" variable is not set
+const defaultHistoryLength = 10000
+
+// History - the step method calls made so far in order of completion. Calls made from inside
+// other calls, such as the Sbash inside SbashTable, have a Depth greater than zero. Only the last
+// "history_length" variable records are kept, 10000 by default, zero keeps none and a negative
+// length keeps all.
+func (s *Step) History() []StepRecord {
+	limit := s.historyLength()
+	if limit >= 0 && len(s.history) > limit {
+		return s.history[len(s.history)-limit:]
+	}
+	return s.history
+}
+
+func (s *Step) historyLength() int {
+	limit, _ := GetIntBinding(s.GetVar(), "history_length", defaultHistoryLength)
+	return limit
+}
+
+// appendHistory - add the record, dropping the oldest records when there are twice the limit so
+// memory stays bounded without copying on every step
+func (s *Step) appendHistory(r StepRecord) {
+	limit := s.historyLength()
+	if limit == 0 {
+		s.history = nil
+		return
+	}
+	s.history = append(s.history, r)
+	if limit > 0 && len(s.history) >= 2*limit {
+		s.history = append([]StepRecord(nil), s.history[len(s.history)-limit:]...)
+	}
+}
+
+// endCalls - end the step methods in progress, innermost first, so a failure which exits records
+// them
+func (s *Step) endCalls() {
+	for len(s.calls) > 0 {
+		s.endCall()
+	}
+}
+
+// beginCall - note the start of a step method. Sub-events such as "Bash.cmd" are passed to
+// the log handler but are not calls.
+func (s *Step) beginCall(info []any) {
+	call := traceCall{start: time.Now()}
+	if len(info) > 0 {
+		call.method = fmt.Sprintf("%v", info[0])
+		info = info[1:]
+	}
+	for _, arg := range info {
+		call.args = append(call.args, s.Self.Redact(fmt.Sprintf("%v", arg)))
+	}
+	if s.handler != nil {
+		s.beginRecord(call)
+	}
+	if strings.Contains(call.method, ".") {
+		return
+	}
+	if len(s.calls) == 0 {
+		s.lastStderr = ""
+	}
+	if s.tracer != nil {
+		s.startSpan(&call)
+	}
+	if len(s.middleware) > 0 {
+		s.startMiddleware(&call)
+	}
+	s.calls = append(s.calls, call)
+}
+
+// endCall - record the outcome of the innermost step method in progress
+func (s *Step) endCall() {
+	if len(s.calls) == 0 {
+		return
+	}
+	call := s.calls[len(s.calls)-1]
+	s.calls = s.calls[:len(s.calls)-1]
+	r := StepRecord{
+		Description: s.Self.Redact(s.description),
+		Method:      call.method,
+		Args:        call.args,
+		Start:       call.start,
+		Duration:    time.Since(call.start),
+		Status:      s.status,
+		Depth:       len(s.calls),
+	}
+	if s.err != nil {
+		r.Error = s.Self.Redact(s.err.Error())
+		r.ExitCode = exitCode(s.err)
+		r.Stderr = s.lastStderr
+	}
+	s.appendHistory(r)
+	if call.span != nil {
+		endSpan(call.span, r)
+	}
+	if s.handler != nil {
+		s.endRecord(r)
+	}
+	if call.stepCall != nil {
+		s.endMiddleware(call, r)
+	}
+}
+
+// exitCode - the exit code of a failed process, or zero if the error is not from a process
+func exitCode(err error) int {
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 0
+}
+
+// Summary - a table of the total time, the failures and the slowest top level steps in the History
+func (s *Step) Summary(slowest int) string {
+	var top []StepRecord
+	var failures RowsOfFields
+	for _, r := range s.History() {
+		if r.Depth > 0 {
+			continue
+		}
+		top = append(top, r)
+		if r.Error != "" {
+			failures = append(failures, []string{r.Description, r.Method, r.Error})
+		}
+	}
+	var b strings.Builder
+	total := time.Duration(0)
+	if len(top) > 0 {
+		total = time.Since(top[0].Start)
+	}
+	b.WriteString(fmt.Sprintf("%d steps, %d failed, total time %s\n", len(top), len(failures), total.Round(time.Millisecond)))
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Duration > top[j].Duration })
+	if len(top) > slowest {
+		top = top[:intMax(slowest, 0)]
+	}
+	if len(top) > 0 {
+		slow := RowsOfFields{{"duration", "description", "method", "args"}}
+		for _, r := range top {
+			slow = append(slow, []string{r.Duration.Round(time.Microsecond).String(), r.Description, r.Method,
+				stringTruncate(strings.Join(r.Args, " "), 40)})
+		}
+		b.WriteString("\nSlowest steps:\n")
+		b.WriteString(slow.Table())
+	}
+	if len(failures) > 0 {
+		b.WriteString("\nFailures:\n")
+		b.WriteString(append(RowsOfFields{{"description", "method", "error"}}, failures...).Table())
+	}
+	return b.String()
+}
+
+// printSummary - print the summary if the "summary" variable is true, the "summary_length"
+// variable sets how many of the slowest steps are shown
+func (s *Step) printSummary() {
+	if s.Var["summary"] != true {
+		return
+	}
+	n, _ := GetIntBinding(s.GetVar(), "summary_length", 5)
+	s.logg.Print("INFO: run summary\n" + s.Summary(n))
+}
+
+// tailBuffer - a writer keeping only the last max bytes written
+type tailBuffer struct {
+	max  int
+	data []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.data = append(t.data, p...)
+	if len(t.data) > t.max {
+		t.data = append([]byte{}, t.data[len(t.data)-t.max:]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,171 @@
+package dianella
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	var s Stepper = BEGIN("start").ContinueOnError(true)
+	s.Set("trace", false).
+		AND("list").
+		Bash("sleep 0.05").
+		SbashTable("echo A", TableOptions{})
+	s.AND("fail").
+		Bash("exit 4").
+		CONTINUE("recover")
+
+	var actual []string
+	for _, r := range s.History() {
+		actual = append(actual, strings.Repeat(">", r.Depth)+r.Method+":"+r.Description)
+	}
+	expected := "Set:start AND:list Bash:list >Sbash:list SbashTable:list AND:fail >FailErr:fail Bash:fail CONTINUE:recover"
+	if strings.Join(actual, " ") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(actual, " "))
+	}
+	bash := s.History()[2]
+	if bash.Duration.Seconds() < 0.05 || bash.Status != 0 || bash.Error != "" {
+		t.Errorf("unexpected record %+v", bash)
+	}
+	failed := s.History()[7]
+	if failed.Status != 1 || failed.Error != "exit status 4" || failed.ExitCode != 4 {
+		t.Errorf("unexpected record %+v", failed)
+	}
+
+	data, err := json.Marshal(s.History())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []StepRecord
+	err = json.Unmarshal(data, &decoded)
+	if err != nil || len(decoded) != len(s.History()) || decoded[7].ExitCode != 4 {
+		t.Errorf("expected JSON round trip, got %v %v", decoded, err)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	t.Parallel()
+	var b bytes.Buffer
+	var s Stepper = BEGIN("start").ContinueOnError(true)
+	s.SetLogger(log.New(&b, "", 0))
+	s.Set("trace", false).
+		Set("summary", true).
+		Set("summary_length", 2).
+		AND("slow").
+		Bash("sleep 0.05").
+		AND("fail").
+		Bash("false").
+		CONTINUE("recover").
+		END()
+	actual := b.String()
+	for _, expected := range []string{
+		"INFO: run summary\n9 steps, 1 failed, total time",
+		"Slowest steps:\nduration",
+		"slow         Bash    sleep 0.05",
+		"Failures:\ndescription  method  error\n-----------  ------  -------------\nfail         Bash    exit status 1\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected '%s' in summary:\n%s", expected, actual)
+		}
+	}
+	summary := actual[strings.Index(actual, "INFO: run summary"):]
+	if strings.Count(summary, "Set ") != 0 || strings.Count(summary, "Bash ") != 3 {
+		t.Errorf("expected two slowest steps:\n%s", summary)
+	}
+}
+
+func TestHistoryLength(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		length   any
+		expected string
+	}{
+		"default":  {nil, "Set:a Set:b Set:c Set:d Set:e Set:f"},
+		"limited":  {3, "Set:e Set:f"},
+		"none":     {0, ""},
+		"all":      {-1, "Set:a Set:b Set:c Set:d Set:e Set:f"},
+		"not int":  {"x", "Set:a Set:b Set:c Set:d Set:e Set:f"},
+		"one only": {1, "Set:f"},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := BEGIN("a")
+			s.Set("trace", false)
+			if plot.length != nil {
+				s.Set("history_length", plot.length)
+			}
+			for _, desc := range []string{"b", "c", "d", "e", "f"} {
+				s.AND(desc).Set("x", desc)
+			}
+			var actual []string
+			for _, r := range s.History() {
+				if r.Method == "Set" && r.Args[0] != "history_length" {
+					actual = append(actual, r.Method+":"+r.Description)
+				}
+			}
+			if strings.Join(actual, " ") != plot.expected {
+				t.Errorf("expected %s, got %s", plot.expected, strings.Join(actual, " "))
+			}
+			if limit, ok := plot.length.(int); ok && limit > 0 && len(s.history) >= 2*limit {
+				t.Errorf("expected at most %d records kept, got %d", 2*limit-1, len(s.history))
+			}
+		})
+	}
+}
+
+// exitRecorder - a Step recording exit codes instead of exiting
+type exitRecorder struct {
+	Step
+	codes []int
+}
+
+func (e *exitRecorder) Exit(code int) { e.codes = append(e.codes, code) }
+
+func TestSummaryNegativeLength(t *testing.T) {
+	t.Parallel()
+	var b bytes.Buffer
+	var s Stepper = BEGIN("start").ContinueOnError(true)
+	s.SetLogger(log.New(&b, "", 0))
+	s.Set("trace", false).
+		Set("summary", true).
+		Set("summary_length", -1).
+		Bash("true").
+		END()
+	if !strings.Contains(b.String(), "5 steps, 0 failed") || strings.Contains(b.String(), "Slowest steps") {
+		t.Errorf("expected a summary without slowest steps:\n%s", b.String())
+	}
+}
+
+func TestSummaryOnFailFast(t *testing.T) {
+	t.Parallel()
+	var b bytes.Buffer
+	s := &exitRecorder{}
+	s.Init(s, t.Name())
+	s.SetLogger(log.New(&b, "", 0))
+	s.Set("trace", false).
+		Set("summary", true).
+		AND("fail").
+		Bash("exit 3").
+		Bash("never runs")
+	actual := b.String()
+	for _, expected := range []string{
+		"INFO: run summary\n4 steps, 1 failed",
+		"fail         Bash    exit status 3",
+		"When fail FailErr: &exec.ExitError",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected '%s' in:\n%s", expected, actual)
+		}
+	}
+	if len(s.codes) != 1 || s.codes[0] != 1 || strings.Contains(actual, "never runs") {
+		t.Errorf("expected one exit with code 1, got %v", s.codes)
+	}
+}
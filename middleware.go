@@ -0,0 +1,54 @@
+package dianella
+
+import "time"
+
+// StepCall - a step method invocation seen by middleware. The arguments are redacted. Duration,
+// Status and Err are filled in when the method ends.
+type StepCall struct {
+	Step        Stepper
+	Description string
+	Method      string
+	Args        []string
+	Start       time.Time
+	Duration    time.Duration
+	Status      int
+	Err         error
+}
+
+// StepMiddleware - called when a step method starts. The function it returns, which may be nil,
+// is called when the method ends, so middleware wraps the invocation like a defer.
+type StepMiddleware func(call *StepCall) func()
+
+// Use - wrap every following step method invocation in the middleware. Middleware starts in the
+// order it was added and ends in the reverse order, so several can be stacked. Unlike overriding
+// Before and After in a subtype the built-in tracing and history are kept.
+func (s *Step) Use(middleware ...StepMiddleware) Stepper {
+	s.middleware = append(s.middleware, middleware...)
+	return s
+}
+
+// startMiddleware - run the middleware for a call starting
+func (s *Step) startMiddleware(call *traceCall) {
+	call.stepCall = &StepCall{
+		Step:        s.Self,
+		Description: s.Self.Redact(s.description),
+		Method:      call.method,
+		Args:        call.args,
+		Start:       call.start,
+	}
+	for _, m := range s.middleware {
+		if end := m(call.stepCall); end != nil {
+			call.ends = append(call.ends, end)
+		}
+	}
+}
+
+// endMiddleware - run the middleware for a call ending, innermost first
+func (s *Step) endMiddleware(call traceCall, r StepRecord) {
+	call.stepCall.Duration = r.Duration
+	call.stepCall.Status = r.Status
+	call.stepCall.Err = s.err
+	for i := len(call.ends) - 1; i >= 0; i-- {
+		call.ends[i]()
+	}
+}
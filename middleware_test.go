@@ -0,0 +1,57 @@
+package dianella
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUse(t *testing.T) {
+	t.Parallel()
+	var events []string
+	named := func(name string) StepMiddleware {
+		return func(call *StepCall) func() {
+			events = append(events, fmt.Sprintf("%s>%s%v", name, call.Method, call.Args))
+			return func() {
+				events = append(events, fmt.Sprintf("%s<%s:%d", name, call.Method, call.Status))
+			}
+		}
+	}
+	var failures []string
+	audit := func(call *StepCall) func() {
+		return func() {
+			if call.Err != nil {
+				failures = append(failures, call.Description+": "+call.Err.Error())
+			}
+		}
+	}
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s.Use(named("a"), named("b")).
+		Use(audit, func(*StepCall) func() { return nil }).
+		SetSecret("token", "s3cr3t").
+		AND("nested").
+		Call(func(s Stepper) Stepper {
+			return s.Set("x", "{{.Var.token}}")
+		}).
+		AND("fails").
+		Fail("broken")
+
+	expected := []string{
+		"a>SetSecret[token *****]", "b>SetSecret[token *****]", "b<SetSecret:0", "a<SetSecret:0",
+		"a>AND[nested]", "b>AND[nested]", "b<AND:0", "a<AND:0",
+		"a>Call[]", "b>Call[]",
+		"a>Set[x {{.Var.token}}]", "b>Set[x {{.Var.token}}]", "b<Set:0", "a<Set:0",
+		"b<Call:0", "a<Call:0",
+		"a>AND[fails]", "b>AND[fails]", "b<AND:0", "a<AND:0",
+		"a>Fail[broken]", "b>Fail[broken]", "b<Fail:1", "a<Fail:1",
+	}
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(events, "\n"))
+	}
+	if len(failures) != 1 || failures[0] != "fails: broken" {
+		t.Errorf("unexpected failures %v", failures)
+	}
+	if len(s.History()) != 6 {
+		t.Errorf("expected history kept, got %v", s.History())
+	}
+}
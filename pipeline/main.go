@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/birchb1024/dianella"
+)
+
+// Main - the dianella command. Runs the pipeline or script file named by the first argument; the
+// arguments after it are parsed with the flags the file declares, and are .Flag and .Arg in
+// templates. A failed step skips the rest up to a "continue", then the command exits with status 1.
+// Go programs can Register their own step types and then call Main.
+func Main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] pipeline.yaml|script [pipeline flags] [args...]\n       %s repl\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	file := flag.Arg(0)
+	if file == "repl" && flag.NArg() == 1 {
+		err := NewREPL(os.Stdout).Run(os.Stdin)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		return
+	}
+	p, err := Load(file)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	flags, args, err := p.ParseFlags(flag.Args()[1:])
+	if err != nil {
+		os.Exit(2)
+	}
+	s := dianella.BEGIN(file)
+	s.ContinueOnError(true)
+	for name, value := range flags {
+		s.Flag[name] = value
+	}
+	s.Arg = args
+	p.Run(s).END()
+}
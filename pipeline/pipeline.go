@@ -0,0 +1,240 @@
+// Package pipeline - runs declarative YAML or JSON pipelines of dianella steps
+package pipeline
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/birchb1024/dianella"
+	"gopkg.in/yaml.v3"
+)
+
+// StepFunc - runs one pipeline step with its parameters, in the Stepper style
+type StepFunc func(s dianella.Stepper, params Params) dianella.Stepper
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]StepFunc{}
+)
+
+// Register - make a step type available to pipelines, replacing any of the same name
+func Register(name string, f StepFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = f
+}
+
+// Lookup - the step type registered with the name
+func Lookup(name string) (StepFunc, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := registry[name]
+	return f, ok
+}
+
+// StepTypes - the names of the registered step types in order
+func StepTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Params - the parameters of a step in a pipeline, with their position for error messages
+type Params struct {
+	File string
+	Line int
+	Name string
+	node *yaml.Node
+}
+
+// NewParams - parameters from a value, for running step types from Go or other front ends
+func NewParams(file string, line int, name string, value any) (Params, error) {
+	var node yaml.Node
+	err := node.Encode(value)
+	return Params{File: file, Line: line, Name: name, node: &node}, err
+}
+
+// Decode - decode the parameters into the value, as yaml.Unmarshal does
+func (p Params) Decode(v any) error {
+	return p.node.Decode(v)
+}
+
+// Errorf - an error prefixed with the position and name of the step
+func (p Params) Errorf(format string, args ...any) error {
+	return &StepError{File: p.File, Line: p.Line, Name: p.Name, Err: fmt.Errorf(format, args...)}
+}
+
+// StepError - an error in a step of a pipeline or script, with its position
+type StepError struct {
+	File string
+	Line int
+	Name string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s:%d: %s: %v", e.File, e.Line, e.Name, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// Fail - fail the step with the error prefixed by its position, unless a step already failed
+func (p Params) Fail(s dianella.Stepper, err error) dianella.Stepper {
+	if !s.IsFailed() {
+		s.FailErr(p.Errorf("%w", err))
+	}
+	return s
+}
+
+// Call - one step of a pipeline
+type Call struct {
+	Params
+	step StepFunc
+}
+
+// Pipeline - a parsed pipeline file
+type Pipeline struct {
+	File  string
+	Flags map[string]any // flag names and defaults, the type of the default is the type of the flag
+	Calls []Call
+}
+
+// document - the mapping form of a pipeline file, the list form is just the steps
+type document struct {
+	Flags map[string]any `yaml:"flags"`
+	Steps yaml.Node      `yaml:"steps"`
+}
+
+// Load - read and parse a pipeline file, a .yaml, .yml or .json file is parsed by Parse and any
+// other by ParseScript
+func Load(file string) (*Pipeline, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return Parse(file, data)
+	}
+	return ParseScript(file, data)
+}
+
+// Parse - parse a pipeline, either a list of steps or a mapping with "flags" and "steps". Each
+// step is a mapping of a registered step type to its parameters, for example `- bash: "ls -l"`.
+// JSON is accepted as it is YAML.
+func Parse(file string, data []byte) (*Pipeline, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	p := &Pipeline{File: file}
+	if len(root.Content) == 0 {
+		return p, nil
+	}
+	steps := root.Content[0]
+	if steps.Kind == yaml.MappingNode {
+		var doc document
+		err = steps.Decode(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		p.Flags = doc.Flags
+		steps = &doc.Steps
+		if steps.Kind == 0 {
+			return p, nil
+		}
+	}
+	p.Calls, err = parseSteps(file, steps)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseSteps - the calls of a list of steps, for pipelines and the bodies of targets
+func parseSteps(file string, steps *yaml.Node) ([]Call, error) {
+	if steps.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%s:%d: expected a list of steps", file, steps.Line)
+	}
+	var calls []Call
+	for _, item := range steps.Content {
+		if item.Kind != yaml.MappingNode || len(item.Content) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a step like '- bash: \"ls\"'", file, item.Line)
+		}
+		name := item.Content[0].Value
+		step, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown step '%s'", file, item.Line, name)
+		}
+		calls = append(calls, Call{
+			Params: Params{File: file, Line: item.Line, Name: name, node: item.Content[1]},
+			step:   step,
+		})
+	}
+	return calls, nil
+}
+
+// ParseFlags - parse the command-line arguments after the pipeline file with the flags the
+// pipeline declares. Returns the flag values, for .Flag, and the remaining arguments, for .Arg.
+func (p *Pipeline) ParseFlags(arguments []string) (map[string]any, []string, error) {
+	fs := flag.NewFlagSet(p.File, flag.ContinueOnError)
+	for name, value := range p.Flags {
+		switch v := value.(type) {
+		case bool:
+			fs.Bool(name, v, "")
+		case int:
+			fs.Int(name, v, "")
+		case float64:
+			fs.Float64(name, v, "")
+		case nil:
+			fs.String(name, "", "")
+		default:
+			fs.String(name, fmt.Sprintf("%v", v), "")
+		}
+	}
+	err := fs.Parse(arguments)
+	if err != nil {
+		return nil, nil, err
+	}
+	flags := map[string]any{}
+	fs.VisitAll(func(f *flag.Flag) { flags[f.Name] = f.Value })
+	return flags, fs.Args(), nil
+}
+
+// Run - run the steps of the pipeline in order. Like a chain of Stepper methods, steps after a
+// failure do nothing unless they are a "continue". The error of a failed step is given the
+// position of the step in the file.
+func (p *Pipeline) Run(s dianella.Stepper) dianella.Stepper {
+	defer s.WrapErrors(nil)
+	runCalls(s, p.Calls)
+	return s
+}
+
+// runCalls - run the calls in order, each failing with its position
+func runCalls(s dianella.Stepper, calls []Call) {
+	for _, c := range calls {
+		s.WrapErrors(c.Params.position)
+		c.step(s, c.Params)
+	}
+}
+
+// position - the error given the position of the step, unless it already has one
+func (p Params) position(err error) error {
+	var positioned *StepError
+	if errors.As(err, &positioned) {
+		return err
+	}
+	return &StepError{File: p.File, Line: p.Line, Name: p.Name, Err: err}
+}
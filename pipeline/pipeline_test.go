@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/birchb1024/dianella"
+)
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		source   string
+		expected string
+	}{
+		"bad yaml":       {"- bash: [", "test.yaml: yaml: line 1: did not find expected node content"},
+		"not a list":     {`"bash"`, "test.yaml:1: expected a list of steps"},
+		"steps not list": {"steps: {bash: ls}", "test.yaml:1: expected a list of steps"},
+		"two keys":       {"- bash: ls\n  and: x", "test.yaml:1: expected a step like '- bash: \"ls\"'"},
+		"unknown step":   {"- and: x\n- bsh: ls", "test.yaml:2: unknown step 'bsh'"},
+		"empty":          {"", ""},
+		"json":           {`[{"bash": "ls"}, {"set": {"a": 1}}]`, ""},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Parse("test.yaml", []byte(plot.source))
+			actual := ""
+			if err != nil {
+				actual = err.Error()
+			}
+			if actual != plot.expected {
+				t.Errorf("expected '%s', got '%s'", plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "batters.csv"), []byte("Name;Runs\nHales;7\nButler;54\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Register("double", func(s dianella.Stepper, params Params) dianella.Stepper {
+		var name string
+		if err := params.Decode(&name); err != nil {
+			return params.Fail(s, err)
+		}
+		value, s := s.GetStringVar(name)
+		return s.Set(name, value+value)
+	})
+	source := `
+flags:
+  team: Australia
+  overs: 20
+  verbose: false
+steps:
+  - and: "set up"
+  - set: {dir: "` + dir + `", title: "{{.Flag.team}} v {{index .Arg 0}}", n: 3}
+  - double: title
+  - sbash: {command: "echo {{.Flag.overs}} {{.Flag.verbose}}", var: overs}
+  - readcsv: {file: "{{.Var.dir}}/batters.csv", var: batters, comma: ";"}
+  - expand:
+      template: "{{.Var.title}}\n{{range .Var.batters}}{{index . 0}}\n{{end}}"
+      file: "` + filepath.Join(dir, "out.txt") + `"
+  - bash: "test {{.Var.n}} = 3"
+`
+	p, err := Parse("test.yaml", []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags, args, err := p.ParseFlags([]string{"-team", "England", "-verbose", "India", "extra"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := dianella.BEGIN(t.Name())
+	s.ContinueOnError(true)
+	for name, value := range flags {
+		s.Flag[name] = value
+	}
+	s.Arg = args
+	p.Run(s)
+
+	if s.IsFailed() {
+		t.Fatalf("unexpected failure %v", s.GetErr())
+	}
+	if s.Var["title"] != "England v IndiaEngland v India" || s.Var["overs"] != "20 true" {
+		t.Errorf("unexpected variables %v", s.Var)
+	}
+	expected := dianella.RowsOfFields{{"Name", "Runs"}, {"Hales", "7"}, {"Butler", "54"}}
+	if !reflect.DeepEqual(s.Var["batters"], expected) {
+		t.Errorf("expected %v, got %v", expected, s.Var["batters"])
+	}
+	out, _ := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if string(out) != "England v IndiaEngland v India\nName\nHales\nButler\n" {
+		t.Errorf("unexpected expansion '%s'", out)
+	}
+}
+
+func TestRunTargets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "src.txt"), []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source := `
+- set: {dir: "` + dir + `", built: 0}
+- target:
+    name: build
+    deps: [generate]
+    inputs: ["{{.Var.dir}}/gen.txt"]
+    outputs: ["{{.Var.dir}}/out.txt"]
+    steps:
+      - bash: "cp {{.Var.dir}}/gen.txt {{.Var.dir}}/out.txt"
+      - sbash: {command: "echo $(( {{.Var.built}} + 1 ))", var: built}
+- target:
+    name: generate
+    inputs: ["{{.Var.dir}}/src.txt"]
+    outputs: ["{{.Var.dir}}/gen.txt"]
+    steps:
+      - bash: "tr a-z A-Z < {{.Var.dir}}/src.txt > {{.Var.dir}}/gen.txt"
+- run: build
+- run: [build]
+`
+	p, err := Parse("test.yaml", []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := quietStep(t.Name()).TargetState(filepath.Join(dir, "state.json"))
+	p.Run(s)
+	if s.IsFailed() {
+		t.Fatalf("unexpected failure %v", s.GetErr())
+	}
+	out, _ := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if string(out) != "ONE" || s.GetVar()["built"] != "1" {
+		t.Errorf("expected one build of ONE, got %v builds of '%s'", s.GetVar()["built"], out)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		source   string
+		expected string
+	}{
+		"command fails":     {"- bash: \"exit 3\"\n- set: {a: 1}", "test.yaml:1: bash: exit status 3"},
+		"bad params":        {"- and: x\n- expand: {template: x}", "test.yaml:2: expand: expected file"},
+		"wrong param type":  {"- bash: {command: ls}", "test.yaml:1: bash: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!map into string"},
+		"bad comma":         {"- readcsv: {file: x.csv, comma: ';;'}", "test.yaml:1: readcsv: comma must be one character, got ';;'"},
+		"continue recovers": {"- fail: broken\n- continue: again\n- set: {a: 1}", ""},
+		"target body fails": {"- target: {name: a, steps: [{and: x}, {bash: \"exit 3\"}]}\n- run: a\n- set: {a: 1}", "test.yaml:1: bash: exit status 3"},
+		"unknown target":    {"- target: {name: a}\n- run: [a, b]\n- set: {a: 1}", "test.yaml:2: run: unknown target 'b'"},
+		"bad target steps":  {"- target: {name: a, steps: [{frobnicate: x}]}", "test.yaml:1: target: test.yaml:1: unknown step 'frobnicate'"},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			p, err := Parse("test.yaml", []byte(plot.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := quietStep(name)
+			p.Run(s)
+			actual := ""
+			if s.GetErr() != nil {
+				actual = s.GetErr().Error()
+			}
+			if actual != plot.expected {
+				t.Errorf("expected '%s', got '%s'", plot.expected, actual)
+			}
+			if _, ok := s.GetVar()["a"]; ok != (plot.expected == "") {
+				t.Errorf("expected steps after a failure to be skipped")
+			}
+		})
+	}
+}
+
+// quietStep - a step which continues on error without tracing
+func quietStep(desc string) dianella.Stepper {
+	return dianella.BEGIN(desc).ContinueOnError(true).Set("trace", false)
+}
+
+func TestStepTypes(t *testing.T) {
+	t.Parallel()
+	types := strings.Join(StepTypes(), ",")
+	for _, name := range []string{"and", "bash", "expand", "readcsv", "set"} {
+		if !strings.Contains(types, name) {
+			t.Errorf("expected step type %s in %s", name, types)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/birchb1024/dianella"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// replHelp - the commands of the REPL
+const replHelp = `commands:
+  bash <command>            run the command
+  sbash <var> <command>     run the command and set the variable to its output
+  set <name> <value>        set the variable, the value is a template
+  expand <file> <template>  expand the template into the file
+  readcsv <file> [var]      read the CSV file into the variable, "rows" by default
+  and <description>         describe the next steps
+  <step type> <yaml>        run any other pipeline step type, e.g. readjson {file: a.json, var: a}
+  {{...}} or .Var.name      print a template expression
+  vars                      list the variables
+  history                   list the steps of the session as a script
+  export go|pipeline|script <file>
+                            write the session as a Go program, pipeline or script
+  help, quit
+`
+
+// replEntry - a step of the session which succeeded, for history and export
+type replEntry struct {
+	name  string
+	value any
+}
+
+// REPL - an interactive session with a live Step. Each successful step is recorded so the session
+// can be exported as a Go program, a pipeline or a script.
+type REPL struct {
+	Step    dianella.Stepper
+	Out     io.Writer
+	line    int
+	entries []replEntry
+}
+
+// NewREPL - a session writing to out, with a Step which continues after failures
+func NewREPL(out io.Writer) *REPL {
+	s := dianella.BEGIN("repl")
+	s.ContinueOnError(true)
+	return &REPL{Step: s, Out: out}
+}
+
+// Execute - run one line, returns true to quit
+func (r *REPL) Execute(line string) bool {
+	r.line++
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return false
+	}
+	if strings.HasPrefix(line, "{{") || strings.HasPrefix(line, ".") {
+		if !strings.HasPrefix(line, "{{") {
+			line = "{{" + line + "}}"
+		}
+		out, err := dianella.Expando(line, r.Step)
+		if err != nil {
+			fmt.Fprintf(r.Out, "error: %v\n", err)
+			return false
+		}
+		fmt.Fprintln(r.Out, out)
+		return false
+	}
+	command, rest, _ := strings.Cut(line, " ")
+	command = strings.ToLower(command)
+	rest = strings.TrimSpace(rest)
+	switch command {
+	case "quit", "exit":
+		return true
+	case "help":
+		fmt.Fprint(r.Out, replHelp)
+		return false
+	case "vars":
+		r.printVars()
+		return false
+	case "history":
+		script, _ := r.ExportScript()
+		fmt.Fprint(r.Out, script)
+		return false
+	case "export":
+		r.export(rest)
+		return false
+	}
+	value, err := replValue(command, rest)
+	if err != nil {
+		fmt.Fprintf(r.Out, "error: %v\n", err)
+		return false
+	}
+	r.run(command, value)
+	return false
+}
+
+// replValue - the parameters of a step type from the rest of a REPL line
+func replValue(command string, rest string) (any, error) {
+	first, remainder, _ := strings.Cut(rest, " ")
+	remainder = strings.TrimSpace(remainder)
+	switch command {
+	case "bash", "and", "continue", "fail", "loadvars":
+		return rest, nil
+	case "set":
+		if first == "" {
+			return nil, fmt.Errorf("expected set <name> <value>")
+		}
+		return map[string]any{first: remainder}, nil
+	case "sbash", "sbashjson":
+		if remainder == "" {
+			return nil, fmt.Errorf("expected %s <var> <command>", command)
+		}
+		return map[string]any{"var": first, "command": remainder}, nil
+	case "expand":
+		if remainder == "" {
+			return nil, fmt.Errorf("expected expand <file> <template>")
+		}
+		return map[string]any{"file": first, "template": remainder}, nil
+	case "readcsv":
+		if first == "" {
+			return nil, fmt.Errorf("expected readcsv <file> [var]")
+		}
+		value := map[string]any{"file": first}
+		if remainder != "" {
+			value["var"] = remainder
+		}
+		return value, nil
+	}
+	if _, ok := Lookup(command); !ok {
+		return nil, fmt.Errorf("unknown command '%s', try help", command)
+	}
+	var value any
+	err := yaml.Unmarshal([]byte(rest), &value)
+	return value, err
+}
+
+// run - run a step, record it if it succeeds, or report the failure and continue
+func (r *REPL) run(name string, value any) {
+	step, _ := Lookup(name)
+	params, err := NewParams("repl", r.line, name, value)
+	if err != nil {
+		fmt.Fprintf(r.Out, "error: %v\n", err)
+		return
+	}
+	p := &Pipeline{File: "repl", Calls: []Call{{Params: params, step: step}}}
+	p.Run(r.Step)
+	if r.Step.IsFailed() {
+		fmt.Fprintf(r.Out, "error: %v\n", r.Step.GetErr())
+		r.Step.CONTINUE(r.Step.GetDescription())
+		return
+	}
+	r.entries = append(r.entries, replEntry{name: name, value: value})
+}
+
+func (r *REPL) printVars() {
+	vars := r.Step.GetVar()
+	for _, name := range varNames(vars) {
+		text := fmt.Sprintf("%v", vars[name])
+		if r.Step.IsSecret(name) {
+			text = "*****"
+		}
+		if len(text) > 60 {
+			text = text[:57] + "..."
+		}
+		fmt.Fprintf(r.Out, "%s = %s\n", name, strings.ReplaceAll(text, "\n", `\n`))
+	}
+}
+
+func varNames(vars map[string]any) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *REPL) export(rest string) {
+	format, file, _ := strings.Cut(rest, " ")
+	file = strings.TrimSpace(file)
+	var text string
+	var err error
+	switch format {
+	case "go":
+		text, err = r.ExportGo()
+	case "pipeline":
+		text, err = r.ExportPipeline()
+	case "script":
+		text, err = r.ExportScript()
+	default:
+		err = fmt.Errorf("expected export go|pipeline|script <file>")
+	}
+	if err == nil && file == "" {
+		err = fmt.Errorf("expected a file name")
+	}
+	if err == nil {
+		err = os.WriteFile(file, []byte(text), 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(r.Out, "error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(r.Out, "wrote %s\n", file)
+}
+
+// ExportPipeline - the session as a YAML pipeline
+func (r *REPL) ExportPipeline() (string, error) {
+	steps := make([]map[string]any, 0, len(r.entries))
+	for _, e := range r.entries {
+		steps = append(steps, map[string]any{e.name: e.value})
+	}
+	out, err := yaml.Marshal(steps)
+	return string(out), err
+}
+
+// methodNames - the Stepper method names of the built-in step types
+var methodNames = map[string]string{
+	"and":       "AND",
+	"continue":  "CONTINUE",
+	"bash":      "Bash",
+	"fail":      "Fail",
+	"loadvars":  "LoadVars",
+	"set":       "Set",
+	"sbash":     "Sbash",
+	"sbashjson": "SbashJSON",
+	"expand":    "Expand",
+	"readcsv":   "ReadCSV",
+	"readjson":  "ReadJSON",
+	"readyaml":  "ReadYAML",
+	"writecsv":  "WriteCSV",
+}
+
+// scriptArgs - the positional arguments of a step in a script, false if they cannot be written
+// as literals
+func scriptArgs(e replEntry) ([]any, bool) {
+	if names, ok := scriptParams[e.name]; ok {
+		m, ok := e.value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		var args []any
+		for _, name := range names {
+			if v, ok := m[name]; ok {
+				args = append(args, v)
+			}
+		}
+		return args, true
+	}
+	if m, ok := e.value.(map[string]any); ok && e.name == "set" {
+		var args []any
+		for _, name := range varNames(m) {
+			args = append(args, name, m[name])
+		}
+		return args, true
+	}
+	switch v := e.value.(type) {
+	case map[string]any:
+		return nil, false
+	case []any:
+		return v, true
+	}
+	return []any{e.value}, true
+}
+
+// goLiteral - a value as a Go literal, strings with newlines as raw strings where possible
+func goLiteral(v any) string {
+	if s, ok := v.(string); ok {
+		if strings.Contains(s, "\n") && !strings.Contains(s, "`") {
+			return "`" + s + "`"
+		}
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
+// ExportScript - the session as a dianella script
+func (r *REPL) ExportScript() (string, error) {
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env dianella\n")
+	for _, e := range r.entries {
+		method, ok := methodNames[e.name]
+		if !ok {
+			method = e.name
+		}
+		args, ok := scriptArgs(e)
+		if !ok {
+			return "", fmt.Errorf("cannot write %s step %v as a script", e.name, e.value)
+		}
+		literals := make([]string, 0, len(args))
+		for _, arg := range args {
+			literals = append(literals, goLiteral(arg))
+		}
+		b.WriteString(fmt.Sprintf("%s(%s)\n", method, strings.Join(literals, ", ")))
+	}
+	b.WriteString("END()\n")
+	return b.String(), nil
+}
+
+// ExportGo - the session as a Go program. Steps without a Stepper method, such as custom step
+// types, are left as comments.
+func (r *REPL) ExportGo() (string, error) {
+	var body strings.Builder
+	usesStrings := false
+	for _, e := range r.entries {
+		args, _ := scriptArgs(e)
+		literals := make([]string, 0, len(args))
+		for _, arg := range args {
+			literals = append(literals, goLiteral(arg))
+		}
+		switch e.name {
+		case "and", "continue", "bash", "fail", "loadvars", "expand", "sbashjson", "readjson", "readyaml":
+			body.WriteString(fmt.Sprintf("\ts.%s(%s)\n", methodNames[e.name], strings.Join(literals, ", ")))
+		case "set":
+			for i := 0; i+1 < len(literals); i += 2 {
+				body.WriteString(fmt.Sprintf("\ts.Set(%s, %s)\n", literals[i], literals[i+1]))
+			}
+		case "sbash":
+			usesStrings = true
+			body.WriteString(fmt.Sprintf("\t{\n\t\tout, _ := s.Sbash(%s)\n\t\ts.GetVar()[%s] = strings.TrimRight(out, \"\\n\")\n\t}\n", literals[0], literals[1]))
+		case "readcsv":
+			variable := `"rows"`
+			if len(literals) > 1 {
+				variable = literals[1]
+			}
+			body.WriteString(fmt.Sprintf("\t{\n\t\t_, rows := s.ReadCSV(%s)\n\t\ts.GetVar()[%s] = rows\n\t}\n", literals[0], variable))
+		default:
+			body.WriteString(fmt.Sprintf("\t// %s %v has no Stepper method\n", e.name, e.value))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("package main\n\nimport (\n\t\"flag\"\n")
+	if usesStrings {
+		b.WriteString("\t\"strings\"\n")
+	}
+	b.WriteString("\n\t. \"github.com/birchb1024/dianella\"\n)\n\nfunc main() {\n\tflag.Parse()\n")
+	b.WriteString("\ts := BEGIN(\"repl session\")\n")
+	b.WriteString(body.String())
+	b.WriteString("\ts.END()\n}\n")
+	return b.String(), nil
+}
+
+// replCommands - the command names offered by tab completion
+func replCommands() []string {
+	names := append([]string{"export", "help", "history", "quit", "vars"}, StepTypes()...)
+	sort.Strings(names)
+	return names
+}
+
+// Complete - tab completion of command names at the start of the line, and of variable names
+// after ".Var." or as the first argument of set and sbash. Returns the completed line and cursor.
+func (r *REPL) Complete(line string, pos int) (string, int, bool) {
+	before := line[:pos]
+	start := strings.LastIndexAny(before, " \t{(") + 1
+	word := before[start:]
+	var candidates []string
+	prefix := word
+	switch {
+	case strings.Contains(word, ".Var."):
+		i := strings.LastIndex(word, ".Var.") + len(".Var.")
+		prefix = word[i:]
+		candidates = varNames(r.Step.GetVar())
+		start += i
+	case start == 0:
+		candidates = replCommands()
+	default:
+		command, _, _ := strings.Cut(strings.TrimSpace(before), " ")
+		if (command == "set" || command == "sbash") && strings.Count(strings.TrimSpace(before[:start]), " ") == 0 {
+			candidates = varNames(r.Step.GetVar())
+		}
+	}
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(matches) == 1 && start == 0 {
+		common += " "
+	}
+	completed := line[:start] + common + line[pos:]
+	return completed, start + len(common), true
+}
+
+// Run - read lines from in until quit or the end of the input, prompting if in is a terminal
+func (r *REPL) Run(in *os.File) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			if r.Execute(scanner.Text()) {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, r.Out}, "dianella> ")
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return r.Complete(line, pos)
+	}
+	fmt.Fprintln(r.Out, `dianella REPL, "help" for commands`)
+	for {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		line, err := t.ReadLine()
+		_ = term.Restore(fd, state)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if r.Execute(line) {
+			return nil
+		}
+	}
+}
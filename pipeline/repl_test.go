@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birchb1024/dianella"
+)
+
+func newTestREPL(t *testing.T) (*REPL, *bytes.Buffer) {
+	var out bytes.Buffer
+	r := NewREPL(&out)
+	r.Step.Set("trace", false)
+	return r, &out
+}
+
+func TestREPLSession(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "batters.csv"), []byte("Name,Runs\nHales,7\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, out := newTestREPL(t)
+	lines := []string{
+		"set dir " + dir,
+		"set team England",
+		"sbash year echo 2024",
+		".Var.team",
+		"{{.Var.team}} in {{.Var.year}}",
+		"bash exit 3",
+		"{{.Var.missing",
+		"frobnicate now",
+		"readcsv {{.Var.dir}}/batters.csv batters",
+		"{{index .Var.batters 1 0}}",
+		"expand {{.Var.dir}}/out.txt {{.Var.team}} {{.Var.year}}",
+		"vars",
+	}
+	for _, line := range lines {
+		if r.Execute(line) {
+			t.Fatalf("unexpected quit at %s", line)
+		}
+	}
+	if !r.Execute("quit") {
+		t.Errorf("expected quit")
+	}
+	expected := strings.Join([]string{
+		"England",
+		"England in 2024",
+		"error: repl:6: bash: exit status 3",
+		"error: template: Expando:1: unclosed action",
+		"error: unknown command 'frobnicate', try help",
+		"Hales",
+		"batters = [[Name Runs] [Hales 7]]",
+		"dir = " + dir,
+		"team = England",
+		"trace = false",
+		"year = 2024",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+	written, _ := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if string(written) != "England 2024" {
+		t.Errorf("unexpected expansion '%s'", written)
+	}
+
+	script, err := r.ExportScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedScript := `#!/usr/bin/env dianella
+Set("dir", "` + dir + `")
+Set("team", "England")
+Sbash("echo 2024", "year")
+ReadCSV("{{.Var.dir}}/batters.csv", "batters")
+Expand("{{.Var.team}} {{.Var.year}}", "{{.Var.dir}}/out.txt")
+END()
+`
+	if script != expectedScript {
+		t.Errorf("expected\n%s\ngot\n%s", expectedScript, script)
+	}
+
+	pipelineText, err := r.ExportPipeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromScript, err := ParseScript("session", []byte(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromPipeline, err := Parse("session.yaml", []byte(pipelineText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*Pipeline{fromScript, fromPipeline} {
+		s := dianella.BEGIN(t.Name()).ContinueOnError(true).Set("trace", false)
+		p.Run(s)
+		if s.IsFailed() || s.GetVar()["year"] != "2024" {
+			t.Errorf("expected %s to replay the session, got %v %v", p.File, s.GetErr(), s.GetVar())
+		}
+	}
+
+	program, err := r.ExportGo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", program, 0)
+	if err != nil || !strings.Contains(program, `s.Set("team", "England")`) || !strings.Contains(program, `"strings"`) {
+		t.Errorf("unexpected program %v\n%s", err, program)
+	}
+}
+
+func TestREPLExport(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	r, out := newTestREPL(t)
+	r.Execute("and greet")
+	r.Execute("bash echo hello >/dev/null")
+	r.Execute("export script " + filepath.Join(dir, "greet"))
+	r.Execute("export pipeline " + filepath.Join(dir, "greet.yaml"))
+	r.Execute("export go " + filepath.Join(dir, "main.go"))
+	r.Execute("export csv x")
+	for _, name := range []string{"greet", "greet.yaml", "main.go"} {
+		p, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || !strings.Contains(string(p), "echo hello >/dev/null") {
+			t.Errorf("unexpected %s: %v %s", name, err, p)
+		}
+	}
+	if !strings.HasSuffix(out.String(), "error: expected export go|pipeline|script <file>\n") {
+		t.Errorf("unexpected output %s", out.String())
+	}
+}
+
+func TestREPLComplete(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		line     string
+		expected string
+	}{
+		"command":           {"ba", "bash "},
+		"common prefix":     {"sb", "sbash"},
+		"no match":          {"zz", ""},
+		"variable":          {"{{.Var.tea", "{{.Var.team"},
+		"variable in bash":  {"bash echo {{.Var.ye", "bash echo {{.Var.year"},
+		"set variable":      {"set tea", "set team"},
+		"set value is free": {"set x te", ""},
+		"common variable":   {".Var.te", ".Var.te"},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r, _ := newTestREPL(t)
+			r.Execute("set team England")
+			r.Execute("set year 2024")
+			r.Execute("set text x")
+			line, pos, ok := r.Complete(plot.line, len(plot.line))
+			if !ok {
+				line = ""
+			}
+			if line != plot.expected || (ok && pos != len(line)) {
+				t.Errorf("expected '%s', got '%s' at %d", plot.expected, line, pos)
+			}
+		})
+	}
+}
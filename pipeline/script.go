@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// scriptParams - the names of the positional arguments of step types in scripts, step types not
+// listed take one argument, or a list if given several
+var scriptParams = map[string][]string{
+	"expand":    {"template", "file"},
+	"readcsv":   {"file", "var"},
+	"readjson":  {"file", "var"},
+	"readyaml":  {"file", "var"},
+	"sbash":     {"command", "var"},
+	"sbashjson": {"command", "var"},
+	"writecsv":  {"file", "var"},
+}
+
+// ParseScript - parse a dianella script, one method call per line with Go literals for arguments:
+//
+//	#!/usr/bin/env dianella
+//	Flag("team", "Australia")
+//	AND("set up")
+//	Set("title", "{{.Flag.team}} batting order")
+//	Bash("echo {{.Var.title}}")
+//	Expand(`{{.Var.title}}`, "title.txt")
+//	END()
+//
+// Method names are the step types of pipelines in any case, so custom step types can be called.
+// Flag declares a flag and its default. END is optional and must be last. A "#!" first line and
+// // comments are ignored.
+func ParseScript(file string, data []byte) (*Pipeline, error) {
+	src := data
+	if strings.HasPrefix(string(src), "#!") {
+		end := strings.IndexByte(string(src), '\n')
+		if end < 0 {
+			end = len(src)
+		}
+		src = append([]byte(strings.Repeat(" ", end)), src[end:]...)
+	}
+	fset := token.NewFileSet()
+	var errs scanner.ErrorList
+	var sc scanner.Scanner
+	sc.Init(fset.AddFile(file, -1, len(src)), src, func(pos token.Position, msg string) { errs.Add(pos, msg) }, 0)
+
+	p := &Pipeline{File: file}
+	ended := false
+	for {
+		pos, tok, lit := sc.Scan()
+		if len(errs) > 0 {
+			return nil, errs[0]
+		}
+		position := fset.Position(pos)
+		switch tok {
+		case token.EOF:
+			return p, nil
+		case token.SEMICOLON:
+			continue
+		case token.IDENT:
+		default:
+			return nil, fmt.Errorf("%s: expected a method call, got '%s'", position, tokenText(tok, lit))
+		}
+		if ended {
+			return nil, fmt.Errorf("%s: %s after END", position, lit)
+		}
+		args, err := scanArgs(&sc, fset)
+		if len(errs) > 0 {
+			err = errs[0]
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.ToLower(lit)
+		switch name {
+		case "end":
+			if len(args) != 0 {
+				return nil, fmt.Errorf("%s: END takes no arguments", position)
+			}
+			ended = true
+			continue
+		case "flag":
+			if len(args) != 2 {
+				return nil, fmt.Errorf("%s: expected Flag(name, default)", position)
+			}
+			if p.Flags == nil {
+				p.Flags = map[string]any{}
+			}
+			p.Flags[fmt.Sprintf("%v", args[0])] = args[1]
+			continue
+		}
+		step, ok := Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown step '%s'", position, lit)
+		}
+		if names, ok := scriptParams[name]; ok && len(args) > len(names) {
+			return nil, fmt.Errorf("%s: expected %s(%s)", position, lit, strings.Join(names, ", "))
+		}
+		params, err := NewParams(file, position.Line, lit, scriptValue(name, args))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", position, err)
+		}
+		p.Calls = append(p.Calls, Call{Params: params, step: step})
+	}
+}
+
+// scanArgs - scan "(literal, ...)" and the end of the line after a method name
+func scanArgs(sc *scanner.Scanner, fset *token.FileSet) ([]any, error) {
+	var args []any
+	expect := func(want token.Token) error {
+		pos, tok, lit := sc.Scan()
+		if tok != want {
+			return fmt.Errorf("%s: expected '%s', got '%s'", fset.Position(pos), want, tokenText(tok, lit))
+		}
+		return nil
+	}
+	if err := expect(token.LPAREN); err != nil {
+		return nil, err
+	}
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.RPAREN && len(args) == 0 {
+			break
+		}
+		negative := tok == token.SUB
+		if negative {
+			pos, tok, lit = sc.Scan()
+		}
+		value, err := literalValue(tok, lit, negative)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fset.Position(pos), err)
+		}
+		args = append(args, value)
+		pos, tok, lit = sc.Scan()
+		if tok == token.RPAREN {
+			break
+		}
+		if tok != token.COMMA {
+			return nil, fmt.Errorf("%s: expected ',' or ')', got '%s'", fset.Position(pos), tokenText(tok, lit))
+		}
+	}
+	pos, tok, lit := sc.Scan()
+	if tok != token.SEMICOLON && tok != token.EOF {
+		return nil, fmt.Errorf("%s: expected one method call per line, got '%s'", fset.Position(pos), tokenText(tok, lit))
+	}
+	return args, nil
+}
+
+// literalValue - the value of a Go string, number, true, false or nil literal
+func literalValue(tok token.Token, lit string, negative bool) (any, error) {
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+	switch {
+	case tok == token.STRING && !negative:
+		return strconv.Unquote(lit)
+	case tok == token.INT:
+		return strconv.Atoi(sign + lit)
+	case tok == token.FLOAT:
+		return strconv.ParseFloat(sign+lit, 64)
+	case tok == token.IDENT && !negative && lit == "true":
+		return true, nil
+	case tok == token.IDENT && !negative && lit == "false":
+		return false, nil
+	case tok == token.IDENT && !negative && lit == "nil":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("expected a string, number, true, false or nil, got '%s'", sign+tokenText(tok, lit))
+}
+
+// scriptValue - the parameters of a step type from the positional arguments of a script call
+func scriptValue(name string, args []any) any {
+	if names, ok := scriptParams[name]; ok {
+		value := map[string]any{}
+		for i, arg := range args {
+			value[names[i]] = arg
+		}
+		return value
+	}
+	switch {
+	case name == "set" && len(args) == 2:
+		return map[string]any{fmt.Sprintf("%v", args[0]): args[1]}
+	case len(args) == 1:
+		return args[0]
+	}
+	return args
+}
+
+// tokenText - a token as written, for messages
+func tokenText(tok token.Token, lit string) string {
+	switch {
+	case tok == token.SEMICOLON && lit == "\n":
+		return "end of line"
+	case lit != "":
+		return lit
+	}
+	return tok.String()
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/birchb1024/dianella"
+)
+
+func TestParseScriptErrors(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		source   string
+		expected string
+	}{
+		"shebang and comments": {"#!/usr/bin/env dianella\n// comment\nAND(\"x\") // trailing\n\nEND()\n", ""},
+		"unknown step":         {"AND(\"x\")\nBsh(\"ls\")", "test.dn:2:1: unknown step 'Bsh'"},
+		"two calls on a line":  {"AND(\"x\") Bash(\"ls\")", "test.dn:1:10: expected one method call per line, got 'Bash'"},
+		"missing paren":        {"Bash \"ls\"", "test.dn:1:6: expected '(', got '\"ls\"'"},
+		"unterminated string":  {"Bash(\"ls)", "test.dn:1:6: string literal not terminated"},
+		"not a literal":        {"Set(\"a\", b)", "test.dn:1:10: expected a string, number, true, false or nil, got 'b'"},
+		"missing comma":        {"Set(\"a\" \"b\")", "test.dn:1:9: expected ',' or ')', got '\"b\"'"},
+		"step after END":       {"END()\nBash(\"ls\")", "test.dn:2:1: Bash after END"},
+		"too many arguments":   {"Expand(\"t\", \"f\", \"x\")", "test.dn:1:1: expected Expand(template, file)"},
+		"not a call":           {"42", "test.dn:1:1: expected a method call, got '42'"},
+		"bad flag":             {"Flag(\"team\")", "test.dn:1:1: expected Flag(name, default)"},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseScript("test.dn", []byte(plot.source))
+			actual := ""
+			if err != nil {
+				actual = err.Error()
+			}
+			if actual != plot.expected {
+				t.Errorf("expected '%s', got '%s'", plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "scorecard")
+	err := os.WriteFile(script, []byte(`#!/usr/bin/env dianella
+Flag("team", "Australia")
+Flag("overs", 20)
+AND("set up")
+Set("dir", "`+dir+`")
+Set("title", "{{.Flag.team}} v {{index .Arg 0}}")
+Set("ratio", -1.5)
+Sbash("echo -n {{.Flag.overs}}", "overs")
+Expand(`+"`{{.Var.title}} {{.Var.overs}} {{.Var.ratio}}\n`"+`, "{{.Var.dir}}/out.txt")
+ReadCSV("{{.Var.dir}}/out.txt", "rows")
+Bash("exit 3")
+CONTINUE("recover")
+Bash("echo {{.Var.title")
+END()
+`), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags, args, err := p.ParseFlags([]string{"-team=England", "India"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := dianella.BEGIN(t.Name())
+	s.ContinueOnError(true).Set("trace", false)
+	for name, value := range flags {
+		s.Flag[name] = value
+	}
+	s.Arg = args
+	p.Run(s)
+
+	expected := "scorecard:14: Bash: template: Expando:1: unclosed action"
+	if s.GetErr() == nil || s.GetErr().Error() != filepath.Join(dir, expected) {
+		t.Errorf("expected '%s', got %v", filepath.Join(dir, expected), s.GetErr())
+	}
+	out, _ := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if string(out) != "England v India 20 -1.5\n" {
+		t.Errorf("unexpected expansion '%s'", out)
+	}
+	if rows, ok := s.Var["rows"].(dianella.RowsOfFields); !ok || len(rows) != 1 || rows[0][0] != "England v India 20 -1.5" {
+		t.Errorf("unexpected rows %v", s.Var["rows"])
+	}
+	var failures []string
+	for _, r := range s.History() {
+		if r.Error != "" && r.Method == "FailErr" {
+			failures = append(failures, r.Args[0])
+		}
+	}
+	expectedFailures := []string{filepath.Join(dir, "scorecard:12: Bash: exit status 3"), filepath.Join(dir, expected)}
+	if strings.Join(failures, "\n") != strings.Join(expectedFailures, "\n") {
+		t.Errorf("expected each failure traced once with its position, got %v", failures)
+	}
+}
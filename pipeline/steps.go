@@ -0,0 +1,291 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/birchb1024/dianella"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	Register("and", text(dianella.Stepper.AND))
+	Register("continue", text(dianella.Stepper.CONTINUE))
+	Register("bash", text(dianella.Stepper.Bash))
+	Register("fail", text(dianella.Stepper.Fail))
+	Register("loadvars", path(dianella.Stepper.LoadVars))
+	Register("set", set)
+	Register("sbash", sbash)
+	Register("sbashjson", sbashJSON)
+	Register("expand", expand)
+	Register("readcsv", readCSV)
+	Register("readjson", readStructured(dianella.Stepper.ReadJSON))
+	Register("readyaml", readStructured(dianella.Stepper.ReadYAML))
+	Register("writecsv", writeCSV)
+	Register("target", target)
+	Register("run", run)
+}
+
+// text - a step taking one string, such as `- bash: "ls -l"`
+func text(method func(dianella.Stepper, string) dianella.Stepper) StepFunc {
+	return func(s dianella.Stepper, params Params) dianella.Stepper {
+		var value string
+		if err := params.Decode(&value); err != nil {
+			return params.Fail(s, err)
+		}
+		return method(s, value)
+	}
+}
+
+// path - a step taking one file path, which is expanded as a template
+func path(method func(dianella.Stepper, string) dianella.Stepper) StepFunc {
+	return func(s dianella.Stepper, params Params) dianella.Stepper {
+		var value string
+		if err := params.Decode(&value); err != nil {
+			return params.Fail(s, err)
+		}
+		if !expandPath(s, params, &value) {
+			return s
+		}
+		return method(s, value)
+	}
+}
+
+// expandPath - expand a file path as a template, failing the step if it will not expand. Unlike
+// Go programs, pipelines have no other way to compute paths.
+func expandPath(s dianella.Stepper, params Params, path *string) bool {
+	if s.IsFailed() {
+		return false
+	}
+	expanded, err := dianella.Expando(*path, s)
+	if err != nil {
+		params.Fail(s, err)
+		return false
+	}
+	*path = expanded
+	return true
+}
+
+// set - `- set: {name: value, ...}` sets the variables in the order written
+func set(s dianella.Stepper, params Params) dianella.Stepper {
+	if params.node.Kind != yaml.MappingNode {
+		return params.Fail(s, fmt.Errorf("expected a mapping of names to values"))
+	}
+	for i := 0; i+1 < len(params.node.Content); i += 2 {
+		var value any
+		if err := params.node.Content[i+1].Decode(&value); err != nil {
+			return params.Fail(s, err)
+		}
+		s.Set(params.node.Content[i].Value, value)
+	}
+	return s
+}
+
+// commandParams - `{command: "...", var: name}` for sbash and sbashjson
+type commandParams struct {
+	Command string `yaml:"command"`
+	Var     string `yaml:"var"`
+}
+
+func (p *commandParams) decode(params Params) error {
+	err := params.Decode(p)
+	if err == nil && (p.Command == "" || p.Var == "") {
+		err = fmt.Errorf("expected command and var")
+	}
+	return err
+}
+
+// sbash - `- sbash: {command: "date", var: today}` sets the variable to the output, without
+// trailing newlines as in $(...)
+func sbash(s dianella.Stepper, params Params) dianella.Stepper {
+	var p commandParams
+	if err := p.decode(params); err != nil {
+		return params.Fail(s, err)
+	}
+	out, _ := s.Sbash(p.Command)
+	if !s.IsFailed() {
+		s.GetVar()[p.Var] = strings.TrimRight(out, "\n")
+	}
+	return s
+}
+
+// sbashJSON - `- sbashjson: {command: "kubectl get pods -o json", var: pods}`
+func sbashJSON(s dianella.Stepper, params Params) dianella.Stepper {
+	var p commandParams
+	if err := p.decode(params); err != nil {
+		return params.Fail(s, err)
+	}
+	return s.SbashJSON(p.Command, p.Var)
+}
+
+// expand - `- expand: {template: "...", file: out.txt}`
+func expand(s dianella.Stepper, params Params) dianella.Stepper {
+	var p struct {
+		Template string `yaml:"template"`
+		File     string `yaml:"file"`
+	}
+	if err := params.Decode(&p); err != nil {
+		return params.Fail(s, err)
+	}
+	if p.File == "" {
+		return params.Fail(s, fmt.Errorf("expected file"))
+	}
+	if !expandPath(s, params, &p.File) {
+		return s
+	}
+	return s.Expand(p.Template, p.File)
+}
+
+// fileVar - `{file: name, var: name}` for reading files into variables
+type fileVar struct {
+	File string `yaml:"file"`
+	Var  string `yaml:"var"`
+}
+
+func readStructured(method func(dianella.Stepper, string, string) dianella.Stepper) StepFunc {
+	return func(s dianella.Stepper, params Params) dianella.Stepper {
+		var p fileVar
+		if err := params.Decode(&p); err != nil {
+			return params.Fail(s, err)
+		}
+		if !expandPath(s, params, &p.File) {
+			return s
+		}
+		return method(s, p.File, p.Var)
+	}
+}
+
+// readCSV - `- readcsv: {file: data.csv, var: rows, comma: ";", no_header: true, ...}` sets the
+// variable, "rows" by default, to the RowsOfFields
+func readCSV(s dianella.Stepper, params Params) dianella.Stepper {
+	var p struct {
+		File            string `yaml:"file"`
+		Var             string `yaml:"var"`
+		Comma           string `yaml:"comma"`
+		Comment         string `yaml:"comment"`
+		LazyQuotes      bool   `yaml:"lazy_quotes"`
+		FieldsPerRecord int    `yaml:"fields_per_record"`
+		StripBOM        bool   `yaml:"strip_bom"`
+		NoHeader        bool   `yaml:"no_header"`
+	}
+	if err := params.Decode(&p); err != nil {
+		return params.Fail(s, err)
+	}
+	if p.Var == "" {
+		p.Var = "rows"
+	}
+	opts := dianella.CSVOptions{
+		LazyQuotes:      p.LazyQuotes,
+		FieldsPerRecord: p.FieldsPerRecord,
+		StripBOM:        p.StripBOM,
+		NoHeader:        p.NoHeader,
+	}
+	var err error
+	if opts.Comma, err = oneRune("comma", p.Comma); err != nil {
+		return params.Fail(s, err)
+	}
+	if opts.Comment, err = oneRune("comment", p.Comment); err != nil {
+		return params.Fail(s, err)
+	}
+	if !expandPath(s, params, &p.File) {
+		return s
+	}
+	_, rows := s.ReadCSVWith(p.File, opts)
+	if !s.IsFailed() {
+		s.GetVar()[p.Var] = rows
+	}
+	return s
+}
+
+// writeCSV - `- writecsv: {file: out.csv, var: rows}` writes RowsOfFields from the variable
+func writeCSV(s dianella.Stepper, params Params) dianella.Stepper {
+	var p fileVar
+	if err := params.Decode(&p); err != nil {
+		return params.Fail(s, err)
+	}
+	rows, ok := s.GetVar()[p.Var].(dianella.RowsOfFields)
+	if !ok {
+		return params.Fail(s, fmt.Errorf("variable '%s' is not rows of fields", p.Var))
+	}
+	if !expandPath(s, params, &p.File) {
+		return s
+	}
+	return s.WriteCSV(p.File, rows)
+}
+
+// target - `- target: {name: build, deps: [generate], inputs: ["*.go"], outputs: [bin/app], steps: [...]}`
+// declares a target for run, the steps are its body. The inputs and outputs are expanded as templates.
+func target(s dianella.Stepper, params Params) dianella.Stepper {
+	var p struct {
+		Name    string    `yaml:"name"`
+		Deps    []string  `yaml:"deps"`
+		Inputs  []string  `yaml:"inputs"`
+		Outputs []string  `yaml:"outputs"`
+		Steps   yaml.Node `yaml:"steps"`
+	}
+	if err := params.Decode(&p); err != nil {
+		return params.Fail(s, err)
+	}
+	if p.Name == "" {
+		return params.Fail(s, fmt.Errorf("expected name"))
+	}
+	var calls []Call
+	if p.Steps.Kind != 0 {
+		var err error
+		if calls, err = parseSteps(params.File, &p.Steps); err != nil {
+			return params.Fail(s, err)
+		}
+	}
+	for _, globs := range [][]string{p.Inputs, p.Outputs} {
+		for i := range globs {
+			if !expandPath(s, params, &globs[i]) {
+				return s
+			}
+		}
+	}
+	s.Target(p.Name, p.Deps, func(s dianella.Stepper) dianella.Stepper {
+		runCalls(s, calls)
+		s.WrapErrors(params.position)
+		return s
+	})
+	if len(p.Inputs) > 0 {
+		s.Inputs(p.Inputs...)
+	}
+	if len(p.Outputs) > 0 {
+		s.Outputs(p.Outputs...)
+	}
+	return s
+}
+
+// run - `- run: [build, test]` runs the targets after their dependencies, or all of them if none
+// are given
+func run(s dianella.Stepper, params Params) dianella.Stepper {
+	var names []string
+	var err error
+	if params.node.Kind == yaml.ScalarNode {
+		var name string
+		err = params.Decode(&name)
+		if name != "" {
+			names = []string{name}
+		}
+	} else {
+		err = params.Decode(&names)
+	}
+	if err != nil {
+		return params.Fail(s, err)
+	}
+	return s.Run(names...)
+}
+
+// oneRune - a single character option, zero if empty
+func oneRune(name string, value string) (rune, error) {
+	if value == "" {
+		return 0, nil
+	}
+	r, size := utf8.DecodeRuneInString(value)
+	if size != len(value) {
+		return 0, fmt.Errorf("%s must be one character, got '%s'", name, value)
+	}
+	return r, nil
+}
@@ -0,0 +1,155 @@
+package dianella
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// StepCase - consecutive top level steps with the same description, a testcase in the reports
+type StepCase struct {
+	Description string
+	Duration    time.Duration
+	Failure     *StepRecord
+}
+
+// StepCases - group the top level history records into cases by description, so each AND()
+// begins a new case. A case fails if any of its steps failed.
+func StepCases(history []StepRecord) []StepCase {
+	var cases []StepCase
+	for i := range history {
+		r := &history[i]
+		if r.Depth > 0 {
+			continue
+		}
+		if len(cases) == 0 || cases[len(cases)-1].Description != r.Description {
+			cases = append(cases, StepCase{Description: r.Description})
+		}
+		c := &cases[len(cases)-1]
+		c.Duration += r.Duration
+		if r.Error != "" && c.Failure == nil {
+			c.Failure = r
+		}
+	}
+	return cases
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+// JUnitReport - the history as JUnit XML, one testcase per StepCase
+func JUnitReport(suiteName string, history []StepRecord) ([]byte, error) {
+	suite := junitSuite{Name: suiteName}
+	total := time.Duration(0)
+	for _, c := range StepCases(history) {
+		jc := junitCase{Name: c.Description, Classname: suiteName, Time: seconds(c.Duration)}
+		if c.Failure != nil {
+			suite.Failures++
+			jc.Failure = &junitFailure{
+				Message: c.Failure.Error,
+				Type:    c.Failure.Method,
+				Text:    strings.Join(append([]string{c.Failure.Method}, c.Failure.Args...), " "),
+			}
+			jc.SystemErr = c.Failure.Stderr
+		}
+		suite.Cases = append(suite.Cases, jc)
+		total += c.Duration
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = seconds(total)
+	if len(history) > 0 {
+		suite.Timestamp = history[0].Start.Format("2006-01-02T15:04:05")
+	}
+	out, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// TAPReport - the history in the Test Anything Protocol, one test per StepCase
+func TAPReport(history []StepRecord) string {
+	cases := StepCases(history)
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("TAP version 13\n1..%d\n", len(cases)))
+	for i, c := range cases {
+		if c.Failure == nil {
+			b.WriteString(fmt.Sprintf("ok %d - %s\n", i+1, c.Description))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("not ok %d - %s\n  ---\n", i+1, c.Description))
+		b.WriteString(fmt.Sprintf("  message: %q\n  method: %s\n", c.Failure.Error, c.Failure.Method))
+		if c.Failure.Stderr != "" {
+			b.WriteString("  stderr: |\n")
+			for _, line := range strings.Split(strings.TrimRight(c.Failure.Stderr, "\n"), "\n") {
+				b.WriteString("    " + line + "\n")
+			}
+		}
+		b.WriteString("  ...\n")
+	}
+	return b.String()
+}
+
+// seconds - a duration in seconds for the JUnit time attributes
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit - write the step history as JUnit XML, the suite is named by the first step's
+// description. Unlike other steps this runs even after a failure, so the report includes it,
+// and a prior failure is kept.
+func (s *Step) WriteJUnit(filename string) Stepper {
+	history := s.Self.History()
+	name := s.Self.Redact(s.description)
+	if len(history) > 0 {
+		name = history[0].Description
+	}
+	report, err := JUnitReport(name, history)
+	if err == nil && !s.dryRunSkip("write JUnit report %s", filename) {
+		err = os.WriteFile(filename, report, 0644)
+	}
+	if err != nil && !s.Self.IsFailed() {
+		s.Self.FailErr(err)
+	}
+	return s
+}
+
+// WriteTAP - write the step history as TAP. Unlike other steps this runs even after a failure,
+// so the report includes it, and a prior failure is kept.
+func (s *Step) WriteTAP(filename string) Stepper {
+	if s.dryRunSkip("write TAP report %s", filename) {
+		return s
+	}
+	err := os.WriteFile(filename, []byte(TAPReport(s.Self.History())), 0644)
+	if err != nil && !s.Self.IsFailed() {
+		s.Self.FailErr(err)
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+package dianella
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func reportFixture(t *testing.T) Stepper {
+	t.Helper()
+	var s Stepper = BEGIN("smoke test").ContinueOnError(true)
+	s.Set("trace", false).Set("stderr_tail", 4096).
+		AND("check web").
+		Bash("true").
+		AND("check <db> & cache").
+		Bash("echo connection refused >&2; exit 2").
+		CONTINUE("check queue").
+		Sbash("true")
+	return s
+}
+
+func TestJUnitReport(t *testing.T) {
+	t.Parallel()
+	s := reportFixture(t)
+	filename := filepath.Join(t.TempDir(), "junit.xml")
+	s.WriteJUnit(filename)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suites junitSuites
+	err = xml.Unmarshal(data, &suites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite := suites.Suites[0]
+	if suite.Name != "smoke test" || suite.Tests != 4 || suite.Failures != 1 {
+		t.Errorf("unexpected suite %+v", suite)
+	}
+	var names []string
+	for _, c := range suite.Cases {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "smoke test,check web,check <db> & cache,check queue" {
+		t.Errorf("unexpected cases %v", names)
+	}
+	failed := suite.Cases[2]
+	if failed.Failure == nil || failed.Failure.Message != "exit status 2" || failed.Failure.Type != "Bash" ||
+		failed.SystemErr != "connection refused\n" {
+		t.Errorf("unexpected failure %+v %+v", failed, failed.Failure)
+	}
+	if !strings.Contains(string(data), "check &lt;db&gt; &amp; cache") {
+		t.Errorf("expected escaped XML, got %s", data)
+	}
+}
+
+func TestTAPReport(t *testing.T) {
+	t.Parallel()
+	s := reportFixture(t)
+	filename := filepath.Join(t.TempDir(), "results.tap")
+	s.WriteTAP(filename)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `TAP version 13
+1..4
+ok 1 - smoke test
+ok 2 - check web
+not ok 3 - check <db> & cache
+  ---
+  message: "exit status 2"
+  method: Bash
+  stderr: |
+    connection refused
+  ...
+ok 4 - check queue
+`
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+}
+
+func TestWriteReportsAfterFailure(t *testing.T) {
+	t.Parallel()
+	var s Stepper = BEGIN("failing").ContinueOnError(true)
+	s.Set("trace", false).Bash("exit 1")
+	filename := filepath.Join(t.TempDir(), "results.tap")
+	s.WriteTAP(filename).WriteJUnit(filepath.Join(t.TempDir(), "junit.xml"))
+	data, err := os.ReadFile(filename)
+	if err != nil || !strings.Contains(string(data), "not ok 1 - failing") {
+		t.Errorf("expected report written after failure, got %s %v", data, err)
+	}
+	if s.GetErr().Error() != "exit status 1" {
+		t.Errorf("expected the prior failure kept, got %v", s.GetErr())
+	}
+	s.CONTINUE("bad path").WriteTAP("/does/not/exist/results.tap")
+	if !s.IsFailed() {
+		t.Errorf("expected failure writing to a missing directory")
+	}
+}
@@ -0,0 +1,199 @@
+package dianella
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// csvField - a struct field and the column it maps to
+type csvField struct {
+	index  int
+	column string
+	layout string
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// csvFields - the exported fields of the struct type. The column is named by the `csv:"name"` tag,
+// or the field name if there is no tag. `csv:"-"` skips the field. Times are formatted with the
+// `layout:"..."` tag, RFC 3339 by default.
+func csvFields(t reflect.Type) []csvField {
+	var fields []csvField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		column := f.Tag.Get("csv")
+		if column == "-" {
+			continue
+		}
+		if column == "" {
+			column = f.Name
+		}
+		layout := f.Tag.Get("layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		fields = append(fields, csvField{index: i, column: column, layout: layout})
+	}
+	return fields
+}
+
+// Decode - convert the rows into the slice of structs pointed to by target, using the header to
+// find the column for each field. See csvFields for the struct tags.
+//
+//	var players []struct {
+//		Name string    `csv:"name"`
+//		Runs int       `csv:"runs"`
+//		Born time.Time `csv:"born" layout:"2006-01-02"`
+//	}
+//	err := rows.Decode(&players)
+func (rows RowsOfFields) Decode(target any) error {
+	pv := reflect.ValueOf(target)
+	if pv.Kind() != reflect.Pointer || pv.Elem().Kind() != reflect.Slice || pv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("expected pointer to slice of struct, got %T", target)
+	}
+	slice := pv.Elem()
+	elemType := slice.Type().Elem()
+	fields := csvFields(elemType)
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = f.column
+	}
+	indexes, err := rows.columnIndexes(columns...)
+	if err != nil {
+		return err
+	}
+	result := reflect.MakeSlice(slice.Type(), 0, len(rows)-1)
+	for r, row := range rows[1:] {
+		elem := reflect.New(elemType).Elem()
+		for i, f := range fields {
+			err := setField(elem.Field(f.index), cellAt(row, indexes[i]), f.layout)
+			if err != nil {
+				return fmt.Errorf("row %d column %s: %w", r+1, f.column, err)
+			}
+		}
+		result = reflect.Append(result, elem)
+	}
+	slice.Set(result)
+	return nil
+}
+
+// setField - parse the cell into the field, empty cells leave the zero value
+func setField(v reflect.Value, cell string, layout string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(cell)
+		return nil
+	}
+	if cell == "" {
+		return nil
+	}
+	switch {
+	case v.Type() == timeType:
+		t, err := time.Parse(layout, cell)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(cell)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType):
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(cell))
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// Encode - convert a slice of structs into rows with a header, the reverse of Decode
+func Encode(slice any) (RowsOfFields, error) {
+	sv := reflect.ValueOf(slice)
+	if sv.Kind() != reflect.Slice || sv.Type().Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected slice of struct, got %T", slice)
+	}
+	fields := csvFields(sv.Type().Elem())
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.column
+	}
+	rows := RowsOfFields{header}
+	for r := 0; r < sv.Len(); r++ {
+		row := make([]string, len(fields))
+		for i, f := range fields {
+			cell, err := formatField(sv.Index(r).Field(f.index), f.layout)
+			if err != nil {
+				return nil, fmt.Errorf("row %d column %s: %w", r+1, f.column, err)
+			}
+			row[i] = cell
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// formatField - the text of a field value for a cell
+func formatField(v reflect.Value, layout string) (string, error) {
+	switch {
+	case v.Type() == timeType:
+		return v.Interface().(time.Time).Format(layout), nil
+	case v.Type() == durationType:
+		return v.Interface().(time.Duration).String(), nil
+	case v.Type().Implements(textMarshalerType):
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	}
+	return "", fmt.Errorf("unsupported field type %s", v.Type())
+}
@@ -0,0 +1,111 @@
+package dianella
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type batter struct {
+	Name    string        `csv:"name"`
+	Runs    int           `csv:"runs"`
+	Average float64       `csv:"avg"`
+	Captain bool          `csv:"captain"`
+	Debut   time.Time     `csv:"debut" layout:"2006-01-02"`
+	Innings time.Duration `csv:"innings"`
+	Note    string        `csv:"-"`
+	hidden  string
+}
+
+func TestRowsOfFields_Decode(t *testing.T) {
+	t.Parallel()
+	given := RowsOfFields{
+		{"name", "runs", "avg", "captain", "debut", "innings", "extra"},
+		{"Butler", "54", "40.5", "true", "2012-03-31", "1h30m", "x"},
+		{"Hales", "", "", "false", "", "", ""},
+	}
+	var actual []batter
+	err := given.Decode(&actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []batter{
+		{Name: "Butler", Runs: 54, Average: 40.5, Captain: true, Debut: time.Date(2012, 3, 31, 0, 0, 0, 0, time.UTC), Innings: 90 * time.Minute},
+		{Name: "Hales"},
+	}
+	if fmt.Sprintf("%v", actual) != fmt.Sprintf("%v", expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestRowsOfFields_DecodeErrors(t *testing.T) {
+	t.Parallel()
+	type ints struct {
+		N int `csv:"n"`
+	}
+	type unsupported struct {
+		M map[string]string `csv:"m"`
+	}
+
+	testTable := map[string]struct {
+		given  RowsOfFields
+		target any
+		errors string
+	}{
+		"not a pointer":  {given: RowsOfFields{{"n"}}, target: []ints{}, errors: "expected pointer to slice of struct"},
+		"no header":      {given: RowsOfFields{}, target: &[]ints{}, errors: "expected header row"},
+		"missing column": {given: RowsOfFields{{"x"}}, target: &[]ints{}, errors: "could not find n"},
+		"bad int":        {given: RowsOfFields{{"n"}, {"1"}, {"two"}}, target: &[]ints{}, errors: "row 2 column n: strconv.ParseInt"},
+		"bad time":       {given: RowsOfFields{{"name", "runs", "avg", "captain", "debut", "innings"}, {"a", "1", "1", "true", "31/3/2012", "1s"}}, target: &[]batter{}, errors: "row 1 column debut"},
+		"unsupported":    {given: RowsOfFields{{"m"}, {"x"}}, target: &[]unsupported{}, errors: "unsupported field type"},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			err := plot.given.Decode(plot.target)
+			if err == nil || !strings.Contains(err.Error(), plot.errors) {
+				t.Errorf("expected error '%v', but got '%v'", plot.errors, err)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+	given := []batter{
+		{Name: "Butler", Runs: 54, Average: 40.5, Captain: true, Debut: time.Date(2012, 3, 31, 0, 0, 0, 0, time.UTC), Innings: 90 * time.Minute},
+	}
+	actual, err := Encode(given)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[[name runs avg captain debut innings] [Butler 54 40.5 true 2012-03-31 1h30m0s]]"
+	if fmt.Sprintf("%v", actual) != expected {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	var roundTrip []batter
+	err = actual.Decode(&roundTrip)
+	if err != nil || fmt.Sprintf("%v", roundTrip) != fmt.Sprintf("%v", given) {
+		t.Errorf("expected %v, got %v %v", given, roundTrip, err)
+	}
+
+	type address struct {
+		IP net.IP `csv:"ip"`
+	}
+	actual, err = Encode([]address{{net.IPv4(10, 0, 0, 1)}})
+	if err != nil || actual[1][0] != "10.0.0.1" {
+		t.Errorf("expected text marshaler, got %v %v", actual, err)
+	}
+	var addresses []address
+	err = actual.Decode(&addresses)
+	if err != nil || !addresses[0].IP.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("expected text unmarshaler, got %v %v", addresses, err)
+	}
+
+	_, err = Encode("not a slice")
+	if err == nil {
+		t.Errorf("expected error for non slice")
+	}
+}
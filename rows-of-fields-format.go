@@ -0,0 +1,186 @@
+package dianella
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// WriteCSV - write the rows to the file in CSV format
+func (s *Step) WriteCSV(filename string, rows RowsOfFields) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("WriteCSV", filename, len(rows))
+	defer s.Self.After()
+	text, err := rows.CSV()
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	if s.dryRunSkip("write %s:\n%s", filename, text) {
+		return s
+	}
+	err = os.WriteFile(filename, []byte(text), 0644)
+	if err != nil {
+		s.Self.FailErr(err)
+	}
+	return s
+}
+
+// CSV - render the rows as comma separated values
+func (rows RowsOfFields) CSV() (string, error) {
+	return rows.delimited(',')
+}
+
+// TSV - render the rows as tab separated values
+func (rows RowsOfFields) TSV() (string, error) {
+	return rows.delimited('\t')
+}
+
+func (rows RowsOfFields) delimited(comma rune) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+	err := w.WriteAll(rows)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// JSON - render the rows as an array of objects keyed by the header row, keys are in header order
+func (rows RowsOfFields) JSON() (string, error) {
+	if len(rows) < 1 {
+		return "", fmt.Errorf("expected header row")
+	}
+	header := rows[0]
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, rec := range rows[1:] {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  {")
+		for idx, h := range header {
+			if idx >= len(rec) {
+				return "", fmt.Errorf("row too short for column %s: %v", h, rec)
+			}
+			if idx > 0 {
+				buf.WriteString(", ")
+			}
+			k, _ := json.Marshal(h)
+			v, _ := json.Marshal(rec[idx])
+			buf.Write(k)
+			buf.WriteString(": ")
+			buf.Write(v)
+		}
+		buf.WriteString("}")
+	}
+	if len(rows) > 1 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	return buf.String(), nil
+}
+
+// Table - render the rows as a plain text table with aligned columns and the header underlined
+func (rows RowsOfFields) Table() string {
+	if len(rows) < 1 {
+		return ""
+	}
+	widths := rows.columnWidths()
+	var b strings.Builder
+	for i, row := range rows {
+		cells := make([]string, len(widths))
+		for c := range widths {
+			cells[c] = padRight(cellAt(row, c), widths[c])
+		}
+		b.WriteString(strings.TrimRight(strings.Join(cells, "  "), " "))
+		b.WriteString("\n")
+		if i == 0 {
+			for c := range widths {
+				cells[c] = strings.Repeat("-", widths[c])
+			}
+			b.WriteString(strings.Join(cells, "  "))
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// Markdown - render the rows as a Markdown table, the first row is the header
+func (rows RowsOfFields) Markdown() string {
+	if len(rows) < 1 {
+		return ""
+	}
+	escaped := make(RowsOfFields, len(rows))
+	for i, row := range rows {
+		escaped[i] = make([]string, len(row))
+		for c, cell := range row {
+			cell = strings.ReplaceAll(cell, "|", `\|`)
+			escaped[i][c] = strings.ReplaceAll(cell, "\n", "<br>")
+		}
+	}
+	widths := escaped.columnWidths()
+	for c := range widths {
+		widths[c] = intMax(widths[c], 3)
+	}
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for _, cell := range cells {
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	cells := make([]string, len(widths))
+	for i, row := range escaped {
+		for c := range widths {
+			cells[c] = padRight(cellAt(row, c), widths[c])
+		}
+		writeRow(cells)
+		if i == 0 {
+			for c := range widths {
+				cells[c] = strings.Repeat("-", widths[c])
+			}
+			writeRow(cells)
+		}
+	}
+	return b.String()
+}
+
+// columnWidths - the widest cell in runes of each column across all rows
+func (rows RowsOfFields) columnWidths() []int {
+	var widths []int
+	for _, row := range rows {
+		for c, cell := range row {
+			if c >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[c] = intMax(widths[c], utf8.RuneCountInString(cell))
+		}
+	}
+	return widths
+}
+
+// cellAt - return the cell in the column or empty if the row is short
+func cellAt(row []string, column int) string {
+	if column < len(row) {
+		return row[column]
+	}
+	return ""
+}
+
+// padRight - pad the text with spaces to width runes
+func padRight(text string, width int) string {
+	n := utf8.RuneCountInString(text)
+	if n >= width {
+		return text
+	}
+	return text + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,112 @@
+package dianella
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var formatFixture = RowsOfFields{
+	{"name", "runs", "note"},
+	{"Hales", "7", "a|b"},
+	{"Butler", "54", `said "hi"`},
+}
+
+func TestRowsOfFields_Formats(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		render   func(RowsOfFields) (string, error)
+		given    RowsOfFields
+		expected string
+		errors   bool
+	}{
+		"csv": {
+			render:   RowsOfFields.CSV,
+			given:    formatFixture,
+			expected: "name,runs,note\nHales,7,a|b\nButler,54,\"said \"\"hi\"\"\"\n",
+		},
+		"tsv": {
+			render:   RowsOfFields.TSV,
+			given:    formatFixture,
+			expected: "name\truns\tnote\nHales\t7\ta|b\nButler\t54\t\"said \"\"hi\"\"\"\n",
+		},
+		"json": {
+			render: RowsOfFields.JSON,
+			given:  formatFixture,
+			expected: `[
+  {"name": "Hales", "runs": "7", "note": "a|b"},
+  {"name": "Butler", "runs": "54", "note": "said \"hi\""}
+]
+`,
+		},
+		"json header only": {
+			render:   RowsOfFields.JSON,
+			given:    RowsOfFields{{"name"}},
+			expected: "[]\n",
+		},
+		"json no header": {
+			render: RowsOfFields.JSON,
+			given:  RowsOfFields{},
+			errors: true,
+		},
+		"json short row": {
+			render: RowsOfFields.JSON,
+			given:  RowsOfFields{{"one", "two"}, {"1"}},
+			errors: true,
+		},
+		"table": {
+			render: func(r RowsOfFields) (string, error) { return r.Table(), nil },
+			given:  formatFixture,
+			expected: `name    runs  note
+------  ----  ---------
+Hales   7     a|b
+Butler  54    said "hi"
+`,
+		},
+		"markdown": {
+			render: func(r RowsOfFields) (string, error) { return r.Markdown(), nil },
+			given:  formatFixture,
+			expected: `| name   | runs | note      |
+| ------ | ---- | --------- |
+| Hales  | 7    | a\|b      |
+| Butler | 54   | said "hi" |
+`,
+		},
+		"markdown empty": {
+			render:   func(r RowsOfFields) (string, error) { return r.Markdown(), nil },
+			given:    RowsOfFields{},
+			expected: "",
+		},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			actual, err := plot.render(plot.given)
+			if plot.errors != (err != nil) {
+				t.Errorf("given %v expected error %v, got %v", plot.given, plot.errors, err)
+				return
+			}
+			if actual != plot.expected {
+				t.Errorf("given %v expected\n%s\nbut got\n%s", plot.given, plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+	filename := filepath.Join(t.TempDir(), "out.csv")
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s = s.WriteCSV(filename, formatFixture)
+	s, actual := s.ReadCSV(filename)
+	if s.IsFailed() {
+		t.Fatal(s.GetErr())
+	}
+	if len(actual) != len(formatFixture) || actual[2][2] != formatFixture[2][2] {
+		t.Errorf("expected %v, got %v", formatFixture, actual)
+	}
+	s = s.WriteCSV(filepath.Join(t.TempDir(), "no", "such", "dir.csv"), formatFixture)
+	if !s.IsFailed() {
+		t.Errorf("expected failure writing to missing directory")
+	}
+}
@@ -0,0 +1,105 @@
+package dianella
+
+import "fmt"
+
+// DuplicateKeys - what RowsOfFields.Index does when two rows have the same key
+type DuplicateKeys int
+
+const (
+	// DuplicateError - a repeated key is an error
+	DuplicateError DuplicateKeys = iota
+	// DuplicateFirstWins - keep the first row with the key
+	DuplicateFirstWins
+	// DuplicateLastWins - keep the last row with the key
+	DuplicateLastWins
+	// DuplicateCollect - keep all the rows with the key, see GetAll
+	DuplicateCollect
+)
+
+// RowIndex - rows keyed by the values of one or more columns. Unlike Rows2Maps the key is a tuple of
+// values, so ("1","23") and ("12","3") are different keys.
+type RowIndex struct {
+	keyColumns []string
+	order      []string
+	tuples     map[string][]string
+	entries    map[string][]map[string]string
+}
+
+// Index - index the rows by the key columns, duplicate keys are handled as requested
+func (rows RowsOfFields) Index(keys []string, duplicates DuplicateKeys) (*RowIndex, error) {
+	indexes, err := rows.columnIndexes(keys...)
+	if err != nil {
+		return nil, err
+	}
+	ix := &RowIndex{
+		keyColumns: append([]string{}, keys...),
+		tuples:     map[string][]string{},
+		entries:    map[string][]map[string]string{},
+	}
+	header := rows[0]
+	for r, rec := range rows[1:] {
+		object := map[string]string{}
+		for idx, h := range header {
+			if idx >= len(rec) {
+				return nil, fmt.Errorf("row too short for column %s: %v", h, rec)
+			}
+			object[h] = rec[idx]
+		}
+		tuple := project(rec, indexes)
+		k := tupleKey(tuple)
+		existing, found := ix.entries[k]
+		if !found {
+			ix.order = append(ix.order, k)
+			ix.tuples[k] = tuple
+			ix.entries[k] = []map[string]string{object}
+			continue
+		}
+		switch duplicates {
+		case DuplicateError:
+			return nil, fmt.Errorf("duplicate key %v at row %d", tuple, r+1)
+		case DuplicateFirstWins:
+		case DuplicateLastWins:
+			ix.entries[k] = []map[string]string{object}
+		case DuplicateCollect:
+			ix.entries[k] = append(existing, object)
+		default:
+			return nil, fmt.Errorf("unknown duplicate key handling %d", duplicates)
+		}
+	}
+	return ix, nil
+}
+
+// KeyColumns - the names of the columns making up the key
+func (ix *RowIndex) KeyColumns() []string { return ix.keyColumns }
+
+// Len - the number of distinct keys
+func (ix *RowIndex) Len() int { return len(ix.order) }
+
+// Keys - the distinct keys in order of first appearance
+func (ix *RowIndex) Keys() [][]string {
+	result := make([][]string, len(ix.order))
+	for i, k := range ix.order {
+		result[i] = ix.tuples[k]
+	}
+	return result
+}
+
+// Has - true if a row has the key values
+func (ix *RowIndex) Has(values ...string) bool {
+	_, ok := ix.entries[tupleKey(values)]
+	return ok
+}
+
+// Get - the row with the key values, the first one if duplicates were collected
+func (ix *RowIndex) Get(values ...string) (map[string]string, bool) {
+	found, ok := ix.entries[tupleKey(values)]
+	if !ok {
+		return nil, false
+	}
+	return found[0], true
+}
+
+// GetAll - all the rows with the key values
+func (ix *RowIndex) GetAll(values ...string) []map[string]string {
+	return ix.entries[tupleKey(values)]
+}
@@ -0,0 +1,83 @@
+package dianella
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var collisions = RowsOfFields{
+	{"a", "b", "name"},
+	{"1", "23", "first"},
+	{"12", "3", "second"},
+	{"1", "23", "third"},
+}
+
+func TestRowsOfFields_Index(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		given      RowsOfFields
+		duplicates DuplicateKeys
+		expected   string
+		errors     string
+	}{
+		"error":       {given: collisions, duplicates: DuplicateError, errors: "duplicate key [1 23] at row 3"},
+		"first wins":  {given: collisions, duplicates: DuplicateFirstWins, expected: "[1 23]:[first] [12 3]:[second] "},
+		"last wins":   {given: collisions, duplicates: DuplicateLastWins, expected: "[1 23]:[third] [12 3]:[second] "},
+		"collect":     {given: collisions, duplicates: DuplicateCollect, expected: "[1 23]:[first third] [12 3]:[second] "},
+		"no rows":     {given: RowsOfFields{{"a", "b"}}, expected: ""},
+		"no header":   {given: RowsOfFields{}, errors: "expected header row"},
+		"missing key": {given: RowsOfFields{{"a"}, {"1"}}, errors: "could not find b"},
+		"short row":   {given: RowsOfFields{{"a", "b", "name"}, {"1", "2"}}, errors: "row too short"},
+		"unknown":     {given: collisions, duplicates: 99, errors: "unknown duplicate key handling"},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			ix, err := plot.given.Index([]string{"a", "b"}, plot.duplicates)
+			if plot.errors != "" {
+				if err == nil || !strings.Contains(err.Error(), plot.errors) {
+					t.Errorf("expected error '%v', but got '%v'", plot.errors, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := ""
+			for _, k := range ix.Keys() {
+				var names []string
+				for _, row := range ix.GetAll(k...) {
+					names = append(names, row["name"])
+				}
+				actual += fmt.Sprintf("%v:%v ", k, names)
+			}
+			if actual != plot.expected {
+				t.Errorf("expected '%v', but got '%v'", plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRowIndex_Lookup(t *testing.T) {
+	t.Parallel()
+	ix, err := collisions.Index([]string{"a", "b"}, DuplicateCollect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Len() != 2 || fmt.Sprintf("%v", ix.KeyColumns()) != "[a b]" {
+		t.Errorf("expected 2 keys on [a b], got %d %v", ix.Len(), ix.KeyColumns())
+	}
+	row, ok := ix.Get("12", "3")
+	if !ok || row["name"] != "second" {
+		t.Errorf("expected second, got %v %v", row, ok)
+	}
+	if ix.Has("123") || ix.Has("1", "2", "3") || !ix.Has("1", "23") {
+		t.Errorf("expected only exact tuples to match")
+	}
+	_, ok = ix.Get("nope", "")
+	if ok || ix.GetAll("nope") != nil {
+		t.Errorf("expected missing key")
+	}
+}
@@ -0,0 +1,352 @@
+package dianella
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JoinKind - selects which unmatched rows a Join keeps
+type JoinKind int
+
+const (
+	// InnerJoin - keep only rows matched in both tables
+	InnerJoin JoinKind = iota
+	// LeftJoin - keep all rows of the receiver, unmatched columns from the other table are empty
+	LeftJoin
+	// FullJoin - keep all rows of both tables
+	FullJoin
+)
+
+// columnIndexes - find the column numbers of the names in the header row
+func (rows RowsOfFields) columnIndexes(names ...string) ([]int, error) {
+	if len(rows) < 1 {
+		return nil, fmt.Errorf("expected header row")
+	}
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		indexes[i] = -1
+		for c, field := range rows[0] {
+			if field == name {
+				indexes[i] = c
+				break
+			}
+		}
+		if indexes[i] == -1 {
+			return nil, fmt.Errorf("could not find %s in columns %v", name, rows[0])
+		}
+	}
+	return indexes, nil
+}
+
+// project - return the cells of the row in the given columns, missing cells are empty
+func project(row []string, indexes []int) []string {
+	result := make([]string, len(indexes))
+	for i, c := range indexes {
+		result[i] = cellAt(row, c)
+	}
+	return result
+}
+
+// tupleKey - an unambiguous map key for a list of values
+func tupleKey(values []string) string {
+	return fmt.Sprintf("%q", values)
+}
+
+// copyHeader - a copy of the header row so results never share it with the receiver
+func (rows RowsOfFields) copyHeader() []string {
+	return append([]string{}, rows[0]...)
+}
+
+// Where - return the header and the rows whose cell in the column satisfies the predicate
+func (rows RowsOfFields) Where(column string, predicate func(string) bool) (RowsOfFields, error) {
+	indexes, err := rows.columnIndexes(column)
+	if err != nil {
+		return nil, err
+	}
+	result := RowsOfFields{rows.copyHeader()}
+	for _, row := range rows[1:] {
+		if predicate(cellAt(row, indexes[0])) {
+			result = append(result, row)
+		}
+	}
+	return result, nil
+}
+
+// SortBy - return the rows stably sorted by the columns in order. Numbers sort numerically and
+// before other cells, which sort as strings.
+func (rows RowsOfFields) SortBy(columns ...string) (RowsOfFields, error) {
+	indexes, err := rows.columnIndexes(columns...)
+	if err != nil {
+		return nil, err
+	}
+	data := append(RowsOfFields{}, rows[1:]...)
+	sort.SliceStable(data, func(i, j int) bool {
+		for _, c := range indexes {
+			cmp := compareCells(cellAt(data[i], c), cellAt(data[j], c))
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+	return append(RowsOfFields{rows.copyHeader()}, data...), nil
+}
+
+// compareCells - numbers compare numerically and before all other cells, which compare as
+// strings, so the order is total whatever mix of cells a column has
+func compareCells(a, b string) int {
+	x, numberX := cellNumber(a)
+	y, numberY := cellNumber(b)
+	switch {
+	case numberX && numberY && x < y:
+		return -1
+	case numberX && numberY && x > y:
+		return 1
+	case numberX && numberY:
+		return 0
+	case numberX:
+		return -1
+	case numberY:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// cellNumber - the value of a cell which is a number, NaN is not
+func cellNumber(cell string) (float64, bool) {
+	x, err := strconv.ParseFloat(cell, 64)
+	return x, err == nil && !math.IsNaN(x)
+}
+
+// Select - return only the columns named, in the order given
+func (rows RowsOfFields) Select(columns ...string) (RowsOfFields, error) {
+	indexes, err := rows.columnIndexes(columns...)
+	if err != nil {
+		return nil, err
+	}
+	result := RowsOfFields{append([]string{}, columns...)}
+	for _, row := range rows[1:] {
+		result = append(result, project(row, indexes))
+	}
+	return result, nil
+}
+
+// Rename - return the rows with a column renamed in the header
+func (rows RowsOfFields) Rename(from, to string) (RowsOfFields, error) {
+	indexes, err := rows.columnIndexes(from)
+	if err != nil {
+		return nil, err
+	}
+	header := rows.copyHeader()
+	header[indexes[0]] = to
+	return append(RowsOfFields{header}, rows[1:]...), nil
+}
+
+// Distinct - return the rows with duplicates removed, keeping the first occurrence
+func (rows RowsOfFields) Distinct() RowsOfFields {
+	if len(rows) < 1 {
+		return RowsOfFields{}
+	}
+	seen := map[string]bool{}
+	result := RowsOfFields{rows.copyHeader()}
+	for _, row := range rows[1:] {
+		k := tupleKey(row)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		result = append(result, row)
+	}
+	return result
+}
+
+// Join - join the rows with another table on equal values in the columns named. The result has the
+// receiver's columns followed by the other table's non-key columns.
+func (rows RowsOfFields) Join(other RowsOfFields, on []string, kind JoinKind) (RowsOfFields, error) {
+	leftKeys, err := rows.columnIndexes(on...)
+	if err != nil {
+		return nil, err
+	}
+	rightKeys, err := other.columnIndexes(on...)
+	if err != nil {
+		return nil, err
+	}
+	isKey := map[int]bool{}
+	for _, c := range rightKeys {
+		isKey[c] = true
+	}
+	var rightRest []int
+	header := rows.copyHeader()
+	for c, field := range other[0] {
+		if !isKey[c] {
+			rightRest = append(rightRest, c)
+			header = append(header, field)
+		}
+	}
+	index := map[string][]int{}
+	for i, row := range other[1:] {
+		k := tupleKey(project(row, rightKeys))
+		index[k] = append(index[k], i+1)
+	}
+	width := len(rows[0])
+	matched := map[int]bool{}
+	result := RowsOfFields{header}
+	for _, row := range rows[1:] {
+		left := project(row, seq(width))
+		found := index[tupleKey(project(row, leftKeys))]
+		for _, r := range found {
+			matched[r] = true
+			result = append(result, append(append([]string{}, left...), project(other[r], rightRest)...))
+		}
+		if len(found) == 0 && kind != InnerJoin {
+			result = append(result, append(left, make([]string, len(rightRest))...))
+		}
+	}
+	if kind == FullJoin {
+		for r := 1; r < len(other); r++ {
+			if matched[r] {
+				continue
+			}
+			left := make([]string, width)
+			for i, c := range leftKeys {
+				left[c] = cellAt(other[r], rightKeys[i])
+			}
+			result = append(result, append(left, project(other[r], rightRest)...))
+		}
+	}
+	return result, nil
+}
+
+// seq - the integers 0 to n-1
+func seq(n int) []int {
+	result := make([]int, n)
+	for i := range result {
+		result[i] = i
+	}
+	return result
+}
+
+// Aggregate - an aggregation over a column of each group, see AggCount, AggSum, AggMin and AggMax
+type Aggregate struct {
+	Name   string
+	Column string
+	reduce func(values []string) (string, error)
+}
+
+// AggCount - the number of rows in each group, in a column called "count"
+func AggCount() Aggregate {
+	return Aggregate{Name: "count", reduce: func(values []string) (string, error) {
+		return strconv.Itoa(len(values)), nil
+	}}
+}
+
+// AggSum - the numeric total of the column in each group, in a column called "sum_<column>"
+func AggSum(column string) Aggregate {
+	return Aggregate{Name: "sum_" + column, Column: column, reduce: func(values []string) (string, error) {
+		total := 0.0
+		for _, v := range values {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", fmt.Errorf("sum of %s: %w", column, err)
+			}
+			total += f
+		}
+		return strconv.FormatFloat(total, 'f', -1, 64), nil
+	}}
+}
+
+// AggMin - the smallest value of the column in each group, in a column called "min_<column>"
+func AggMin(column string) Aggregate {
+	return Aggregate{Name: "min_" + column, Column: column, reduce: func(values []string) (string, error) {
+		return extreme(values, -1), nil
+	}}
+}
+
+// AggMax - the largest value of the column in each group, in a column called "max_<column>"
+func AggMax(column string) Aggregate {
+	return Aggregate{Name: "max_" + column, Column: column, reduce: func(values []string) (string, error) {
+		return extreme(values, 1), nil
+	}}
+}
+
+// extreme - the value comparing furthest in the direction given, using compareCells
+func extreme(values []string, direction int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	result := values[0]
+	for _, v := range values[1:] {
+		if compareCells(v, result)*direction > 0 {
+			result = v
+		}
+	}
+	return result
+}
+
+// Grouping - rows partitioned by the values of some columns, ready to aggregate with Agg
+type Grouping struct {
+	rows    RowsOfFields
+	columns []string
+	indexes []int
+	err     error
+}
+
+// GroupBy - partition the rows by the values in the columns, call Agg on the result
+func (rows RowsOfFields) GroupBy(columns ...string) Grouping {
+	indexes, err := rows.columnIndexes(columns...)
+	return Grouping{rows: rows, columns: columns, indexes: indexes, err: err}
+}
+
+// Agg - return one row per group, in order of first appearance, with the group columns
+// followed by the aggregates
+func (g Grouping) Agg(aggregates ...Aggregate) (RowsOfFields, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+	header := append([]string{}, g.columns...)
+	aggIndexes := make([]int, len(aggregates))
+	for i, a := range aggregates {
+		header = append(header, a.Name)
+		aggIndexes[i] = -1
+		if a.Column == "" {
+			continue
+		}
+		c, err := g.rows.columnIndexes(a.Column)
+		if err != nil {
+			return nil, err
+		}
+		aggIndexes[i] = c[0]
+	}
+	var order []string
+	groups := map[string]RowsOfFields{}
+	for _, row := range g.rows[1:] {
+		k := tupleKey(project(row, g.indexes))
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], row)
+	}
+	result := RowsOfFields{header}
+	for _, k := range order {
+		members := groups[k]
+		out := project(members[0], g.indexes)
+		for i, a := range aggregates {
+			values := make([]string, len(members))
+			if aggIndexes[i] >= 0 {
+				for m, row := range members {
+					values[m] = cellAt(row, aggIndexes[i])
+				}
+			}
+			v, err := a.reduce(values)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		result = append(result, out)
+	}
+	return result, nil
+}
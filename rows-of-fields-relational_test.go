@@ -0,0 +1,162 @@
+package dianella
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var players = RowsOfFields{
+	{"name", "team", "runs"},
+	{"Hales", "ENG", "7"},
+	{"Butler", "ENG", "54"},
+	{"Smith", "AUS", "100"},
+	{"Warner", "AUS", "9"},
+	{"Hales", "ENG", "7"},
+}
+
+var teams = RowsOfFields{
+	{"team", "country"},
+	{"ENG", "England"},
+	{"NZ", "New Zealand"},
+}
+
+func TestRowsOfFields_Relational(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		operation func() (RowsOfFields, error)
+		expected  string
+		errors    string
+	}{
+		"where": {
+			operation: func() (RowsOfFields, error) {
+				return players.Where("team", func(v string) bool { return v == "AUS" })
+			},
+			expected: "[[name team runs] [Smith AUS 100] [Warner AUS 9]]",
+		},
+		"where unknown column": {
+			operation: func() (RowsOfFields, error) {
+				return players.Where("ZZZ", func(v string) bool { return true })
+			},
+			errors: "could not find ZZZ",
+		},
+		"where no header": {
+			operation: func() (RowsOfFields, error) {
+				return RowsOfFields{}.Where("team", func(v string) bool { return true })
+			},
+			errors: "expected header row",
+		},
+		"sort numeric": {
+			operation: func() (RowsOfFields, error) { return players.SortBy("runs") },
+			expected:  "[[name team runs] [Hales ENG 7] [Hales ENG 7] [Warner AUS 9] [Butler ENG 54] [Smith AUS 100]]",
+		},
+		"sort two columns": {
+			operation: func() (RowsOfFields, error) { return players.SortBy("team", "name") },
+			expected:  "[[name team runs] [Smith AUS 100] [Warner AUS 9] [Butler ENG 54] [Hales ENG 7] [Hales ENG 7]]",
+		},
+		"sort unknown column": {
+			operation: func() (RowsOfFields, error) { return players.SortBy("team", "ZZZ") },
+			errors:    "could not find ZZZ",
+		},
+		"select": {
+			operation: func() (RowsOfFields, error) { return teams.Select("country", "team") },
+			expected:  "[[country team] [England ENG] [New Zealand NZ]]",
+		},
+		"select unknown column": {
+			operation: func() (RowsOfFields, error) { return teams.Select("ZZZ") },
+			errors:    "could not find ZZZ",
+		},
+		"rename": {
+			operation: func() (RowsOfFields, error) { return teams.Rename("team", "code") },
+			expected:  "[[code country] [ENG England] [NZ New Zealand]]",
+		},
+		"rename unknown column": {
+			operation: func() (RowsOfFields, error) { return teams.Rename("ZZZ", "code") },
+			errors:    "could not find ZZZ",
+		},
+		"distinct": {
+			operation: func() (RowsOfFields, error) { return players.Distinct(), nil },
+			expected:  "[[name team runs] [Hales ENG 7] [Butler ENG 54] [Smith AUS 100] [Warner AUS 9]]",
+		},
+		"inner join": {
+			operation: func() (RowsOfFields, error) { return players.Distinct().Join(teams, []string{"team"}, InnerJoin) },
+			expected:  "[[name team runs country] [Hales ENG 7 England] [Butler ENG 54 England]]",
+		},
+		"left join": {
+			operation: func() (RowsOfFields, error) { return players.Distinct().Join(teams, []string{"team"}, LeftJoin) },
+			expected:  "[[name team runs country] [Hales ENG 7 England] [Butler ENG 54 England] [Smith AUS 100 ] [Warner AUS 9 ]]",
+		},
+		"full join": {
+			operation: func() (RowsOfFields, error) { return players.Distinct().Join(teams, []string{"team"}, FullJoin) },
+			expected:  "[[name team runs country] [Hales ENG 7 England] [Butler ENG 54 England] [Smith AUS 100 ] [Warner AUS 9 ] [ NZ  New Zealand]]",
+		},
+		"join unknown column": {
+			operation: func() (RowsOfFields, error) { return players.Join(teams, []string{"name"}, InnerJoin) },
+			errors:    "could not find name",
+		},
+		"group by": {
+			operation: func() (RowsOfFields, error) {
+				return players.GroupBy("team").Agg(AggCount(), AggSum("runs"), AggMin("runs"), AggMax("name"))
+			},
+			expected: "[[team count sum_runs min_runs max_name] [ENG 3 68 7 Hales] [AUS 2 109 9 Warner]]",
+		},
+		"group by unknown column": {
+			operation: func() (RowsOfFields, error) { return players.GroupBy("ZZZ").Agg(AggCount()) },
+			errors:    "could not find ZZZ",
+		},
+		"group by unknown aggregate column": {
+			operation: func() (RowsOfFields, error) { return players.GroupBy("team").Agg(AggSum("ZZZ")) },
+			errors:    "could not find ZZZ",
+		},
+		"group by sum not numeric": {
+			operation: func() (RowsOfFields, error) { return players.GroupBy("team").Agg(AggSum("name")) },
+			errors:    "sum of name",
+		},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			actual, err := plot.operation()
+			if plot.errors != "" {
+				if err == nil || !strings.Contains(err.Error(), plot.errors) {
+					t.Errorf("expected error '%v', but got '%v'", plot.errors, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%v", actual) != plot.expected {
+				t.Errorf("expected '%v', but got '%v'", plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCompareCellsIsTransitive(t *testing.T) {
+	t.Parallel()
+	cells := []string{"9", "10", "1a", "NaN", "", "-2.5", "abc"}
+	expected := "[-2.5 9 10  1a NaN abc]"
+	for i := range cells {
+		rotated := append(append([]string{}, cells[i:]...), cells[:i]...)
+		sort.Slice(rotated, func(a, b int) bool { return compareCells(rotated[a], rotated[b]) < 0 })
+		if fmt.Sprintf("%v", rotated) != expected {
+			t.Errorf("expected %s, got %v", expected, rotated)
+		}
+		if min, max := extreme(rotated, -1), extreme(rotated, 1); min != "-2.5" || max != "abc" {
+			t.Errorf("expected min -2.5 and max abc, got %s and %s", min, max)
+		}
+	}
+}
+
+func TestRowsOfFields_RelationalDoesNotModify(t *testing.T) {
+	t.Parallel()
+	given := RowsOfFields{{"a", "b"}, {"2", "x"}, {"1", "y"}}
+	_, _ = given.SortBy("a")
+	_, _ = given.Rename("a", "z")
+	if fmt.Sprintf("%v", given) != "[[a b] [2 x] [1 y]]" {
+		t.Errorf("receiver was modified: %v", given)
+	}
+}
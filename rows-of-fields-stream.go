@@ -0,0 +1,123 @@
+package dianella
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrStopIteration - returned by an EachCSVRow function to stop reading early without failing the step
+var ErrStopIteration = errors.New("stop iteration")
+
+// CSVIterator - reads CSV records one at a time so large files are processed in constant memory
+//
+//	it := NewCSVIterator(f, CSVOptions{})
+//	for it.Next() {
+//		fmt.Println(it.Line(), it.Map()["name"])
+//	}
+//	if it.Err() != nil { ... }
+type CSVIterator struct {
+	reader   *csv.Reader
+	header   []string
+	row      []string
+	line     int
+	err      error
+	noHeader bool
+}
+
+// NewCSVIterator - an iterator over the CSV records of the reader. Unless opts.NoHeader is set the
+// first record is the header.
+func NewCSVIterator(in io.Reader, opts CSVOptions) *CSVIterator {
+	r := opts.newReader(in)
+	r.ReuseRecord = true
+	return &CSVIterator{reader: r, noHeader: opts.NoHeader}
+}
+
+// Next - advance to the next row, false at the end of the input or on an error
+func (it *CSVIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	rec, err := it.reader.Read()
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.line, _ = it.reader.FieldPos(0)
+	if it.header == nil {
+		if it.noHeader {
+			it.header = synthesizedHeader(len(rec))
+		} else {
+			it.header = append([]string{}, rec...)
+			return it.Next()
+		}
+	}
+	it.row = rec
+	return true
+}
+
+// Header - the header row, read or synthesized
+func (it *CSVIterator) Header() []string { return it.header }
+
+// Row - the fields of the current row, only valid until the next call to Next
+func (it *CSVIterator) Row() []string { return it.row }
+
+// Line - the line number in the input where the current row starts
+func (it *CSVIterator) Line() int { return it.line }
+
+// Err - the error which stopped the iteration, nil at the end of the input
+func (it *CSVIterator) Err() error { return it.err }
+
+// Map - the current row keyed by the header, missing fields are empty and extra fields ignored
+func (it *CSVIterator) Map() map[string]string {
+	object := make(map[string]string, len(it.header))
+	for idx, h := range it.header {
+		object[h] = cellAt(it.row, idx)
+	}
+	return object
+}
+
+// EachCSVRow - call the function for each row of the CSV file in turn without reading the whole
+// file into memory. Returning ErrStopIteration ends the loop early, any other error fails the step.
+func (s *Step) EachCSVRow(filename string, f func(header []string, row map[string]string) error) Stepper {
+	return s.Self.EachCSVRowWith(filename, CSVOptions{}, f)
+}
+
+// EachCSVRowWith - EachCSVRow with CSV options, the filename "-" reads standard input
+func (s *Step) EachCSVRowWith(filename string, opts CSVOptions, f func(header []string, row map[string]string) error) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("EachCSVRow", filename)
+	defer s.Self.After()
+	var in io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			s.Self.FailErr(err)
+			return s
+		}
+		defer func() { _ = file.Close() }()
+		in = file
+	}
+	it := NewCSVIterator(in, opts)
+	for it.Next() {
+		err := f(it.Header(), it.Map())
+		if errors.Is(err, ErrStopIteration) {
+			return s
+		}
+		if err != nil {
+			s.Self.FailErr(fmt.Errorf("%s:%d: %w", filename, it.Line(), err))
+			return s
+		}
+	}
+	if it.Err() != nil {
+		s.Self.FailErr(fmt.Errorf("%s: %w", filename, it.Err()))
+	}
+	return s
+}
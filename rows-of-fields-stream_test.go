@@ -0,0 +1,110 @@
+package dianella
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCSVIterator(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		given    string
+		opts     CSVOptions
+		expected string
+		errors   string
+	}{
+		"empty":       {given: "", expected: ""},
+		"header only": {given: "a,b\n", expected: ""},
+		"rows": {
+			given:    "a,b\n1,2\n\n3,4\n",
+			expected: "2:[1 2]map[a:1 b:2] 4:[3 4]map[a:3 b:4] ",
+		},
+		"no header": {
+			given:    "1,2\n3,4\n",
+			opts:     CSVOptions{NoHeader: true},
+			expected: "1:[1 2]map[column1:1 column2:2] 2:[3 4]map[column1:3 column2:4] ",
+		},
+		"ragged": {
+			given:    "a,b\n1\n",
+			opts:     CSVOptions{FieldsPerRecord: -1},
+			expected: "2:[1]map[a:1 b:] ",
+		},
+		"bad row": {
+			given:    "a,b\n1,2\n3\n",
+			expected: "2:[1 2]map[a:1 b:2] ",
+			errors:   "record on line 3: wrong number of fields",
+		},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			it := NewCSVIterator(strings.NewReader(plot.given), plot.opts)
+			actual := ""
+			for it.Next() {
+				actual += fmt.Sprintf("%d:%v%v ", it.Line(), it.Row(), it.Map())
+			}
+			if actual != plot.expected {
+				t.Errorf("expected '%v', but got '%v'", plot.expected, actual)
+			}
+			if plot.errors == "" && it.Err() != nil {
+				t.Error(it.Err())
+			}
+			if plot.errors != "" && (it.Err() == nil || !strings.Contains(it.Err().Error(), plot.errors)) {
+				t.Errorf("expected error '%v', but got '%v'", plot.errors, it.Err())
+			}
+			if it.Next() {
+				t.Errorf("expected Next to stay false")
+			}
+		})
+	}
+}
+
+func TestEachCSVRow(t *testing.T) {
+	t.Parallel()
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	var seen []string
+	s = s.EachCSVRow("test/fixture.csv", func(header []string, row map[string]string) error {
+		seen = append(seen, row["two"])
+		return nil
+	})
+	if s.IsFailed() {
+		t.Fatal(s.GetErr())
+	}
+	if fmt.Sprintf("%v", seen) != "[2 6 10]" {
+		t.Errorf("expected [2 6 10], got %v", seen)
+	}
+
+	seen = nil
+	s = s.EachCSVRow("test/fixture.csv", func(header []string, row map[string]string) error {
+		seen = append(seen, row["one"])
+		return ErrStopIteration
+	})
+	if s.IsFailed() || len(seen) != 1 {
+		t.Errorf("expected early stop without failure, got %v %v", seen, s.GetErr())
+	}
+
+	s = s.EachCSVRow("test/fixture.csv", func(header []string, row map[string]string) error {
+		if row["one"] == "5" {
+			return errors.New("bad five")
+		}
+		return nil
+	})
+	if !s.IsFailed() || s.GetErr().Error() != "test/fixture.csv:3: bad five" {
+		t.Errorf("expected row error with line number, got %v", s.GetErr())
+	}
+
+	s.CONTINUE("ragged file")
+	s = s.EachCSVRow("test/badfixture.csv", func(header []string, row map[string]string) error { return nil })
+	if !s.IsFailed() || !strings.Contains(s.GetErr().Error(), "line 2") {
+		t.Errorf("expected parse error with line number, got %v", s.GetErr())
+	}
+
+	s.CONTINUE("missing file")
+	s = s.EachCSVRow("test/nosuchfile.csv", func(header []string, row map[string]string) error { return nil })
+	if !s.IsFailed() {
+		t.Errorf("expected missing file failure")
+	}
+}
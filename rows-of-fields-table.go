@@ -0,0 +1,212 @@
+package dianella
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// TableSplit - how ParseTable divides lines of text into fields
+type TableSplit int
+
+const (
+	// SplitWhitespace - fields are separated by runs of whitespace, the last column takes the rest of the line
+	SplitWhitespace TableSplit = iota
+	// SplitFixedWidth - columns start where the words of the header line start, a header of several
+	// words separated by single spaces such as "CONTAINER ID" is one column
+	SplitFixedWidth
+	// SplitRegexp - the named groups of TableOptions.Regexp are the columns, lines not matching are skipped
+	SplitRegexp
+	// SplitDelimiter - fields are separated by TableOptions.Delimiter
+	SplitDelimiter
+)
+
+// TableOptions - controls ParseTable and SbashTable
+type TableOptions struct {
+	Split     TableSplit
+	Delimiter string // for SplitDelimiter
+	Regexp    string // for SplitRegexp
+	SkipLines int    // number of leading lines to ignore before the header
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// SbashTable - run the command like Sbash and parse the output into RowsOfFields with ParseTable
+func (s *Step) SbashTable(cmd string, opts TableOptions) (RowsOfFields, Stepper) {
+	if s.Self.IsFailed() {
+		return nil, s
+	}
+	s.Self.Before("SbashTable", cmd)
+	defer s.Self.After()
+	out, _ := s.Self.Sbash(cmd)
+	if s.Self.IsFailed() {
+		return nil, s
+	}
+	rows, err := ParseTable(out, opts)
+	if err != nil {
+		s.Self.FailErr(err)
+		return nil, s
+	}
+	return rows, s
+}
+
+// ParseTable - parse columns of text such as the output of ps, df or kubectl get into RowsOfFields.
+// The first line is the header, except for SplitRegexp where the group names are the header.
+// Blank lines are ignored.
+func ParseTable(text string, opts TableOptions) (RowsOfFields, error) {
+	var lines []string
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if i < opts.SkipLines || strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	switch opts.Split {
+	case SplitWhitespace:
+		return parseWhitespace(lines), nil
+	case SplitFixedWidth:
+		return parseFixedWidth(lines), nil
+	case SplitRegexp:
+		return parseRegexp(lines, opts.Regexp)
+	case SplitDelimiter:
+		if opts.Delimiter == "" {
+			return nil, fmt.Errorf("missing delimiter for SplitDelimiter")
+		}
+		return parseDelimited(lines, opts.Delimiter), nil
+	}
+	return nil, fmt.Errorf("unknown table split %d", opts.Split)
+}
+
+func parseWhitespace(lines []string) RowsOfFields {
+	if len(lines) == 0 {
+		return RowsOfFields{}
+	}
+	// A header with more words than any line has fields has a column name of several words
+	header := strings.Fields(lines[0])
+	fields := 0
+	for _, line := range lines[1:] {
+		fields = intMax(fields, len(strings.Fields(line)))
+	}
+	if len(lines) > 1 && len(header) > fields {
+		header, _ = headerColumns(lines)
+	}
+	rows := RowsOfFields{header}
+	for _, line := range lines[1:] {
+		rows = append(rows, whitespace.Split(strings.TrimSpace(line), len(header)))
+	}
+	return rows
+}
+
+// headerColumns - the column names of the header line and where they start. Words separated by
+// two or more spaces are separate columns. Words separated by one space are one column, such as
+// "CONTAINER ID" or "Mounted on", when a value in another line runs across the space or no line
+// has a value under the second word.
+func headerColumns(lines []string) ([]string, []int) {
+	header := []rune(lines[0])
+	data := make([][]rune, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		data = append(data, []rune(line))
+	}
+	var names []string
+	var starts []int
+	end := 0
+	for _, loc := range regexp.MustCompile(`\S+`).FindAllStringIndex(lines[0], -1) {
+		start, stop := utf8.RuneCountInString(lines[0][:loc[0]]), utf8.RuneCountInString(lines[0][:loc[1]])
+		if len(names) > 0 && start-end == 1 && oneColumn(data, end, stop) {
+			names[len(names)-1] = string(header[starts[len(starts)-1]:stop])
+		} else {
+			names = append(names, string(header[start:stop]))
+			starts = append(starts, start)
+		}
+		end = stop
+	}
+	return names, starts
+}
+
+// oneColumn - true if the header words either side of the space at gap, the second ending at
+// stop, name one column
+func oneColumn(lines [][]rune, gap int, stop int) bool {
+	under := false
+	for _, line := range lines {
+		if gap < len(line) && line[gap-1] != ' ' && line[gap] != ' ' {
+			return true
+		}
+		for i := gap + 1; i < stop && i < len(line); i++ {
+			under = under || line[i] != ' '
+		}
+	}
+	return !under
+}
+
+func parseFixedWidth(lines []string) RowsOfFields {
+	if len(lines) == 0 {
+		return RowsOfFields{}
+	}
+	header, starts := headerColumns(lines)
+	rows := RowsOfFields{header}
+	for _, text := range lines[1:] {
+		line := []rune(text)
+		// A right aligned value wider than its header overlaps the column start, move the
+		// boundary back to the start of the word so it is not split
+		bounds := make([]int, len(starts)+1)
+		for c, start := range starts {
+			for start > 0 && start < len(line) && line[start-1] != ' ' && line[start] != ' ' {
+				start--
+			}
+			bounds[c] = intMin(start, len(line))
+		}
+		bounds[len(starts)] = len(line)
+		row := make([]string, len(starts))
+		for c := range starts {
+			if bounds[c] < bounds[c+1] {
+				row[c] = strings.TrimSpace(string(line[bounds[c]:bounds[c+1]]))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func parseRegexp(lines []string, expression string) (RowsOfFields, error) {
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	var groups []int
+	var names []string
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			groups = append(groups, i)
+			names = append(names, name)
+		}
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("regexp has no named groups: %s", expression)
+	}
+	rows := RowsOfFields{names}
+	for _, line := range lines {
+		match := re.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		row := make([]string, len(groups))
+		for c, g := range groups {
+			row[c] = match[g]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseDelimited(lines []string, delimiter string) RowsOfFields {
+	rows := RowsOfFields{}
+	for _, line := range lines {
+		row := strings.Split(line, delimiter)
+		for c := range row {
+			row[c] = strings.TrimSpace(row[c])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
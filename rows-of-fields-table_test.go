@@ -0,0 +1,151 @@
+package dianella
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const psOutput = `  PID TTY          TIME CMD
+    1 ?        00:00:02 /sbin/init splash
+  812 pts/0    00:00:00 bash
+`
+
+const dfOutput = `Filesystem     Size  Used Avail Use% Mounted
+/dev/sda1       50G   20G   28G  42% /
+tmpfs          7.8G     0  7.8G   0% /dev/shm
+`
+
+const dockerPsOutput = `CONTAINER ID   IMAGE        COMMAND                  CREATED       STATUS       PORTS                NAMES
+4c01db0b339c   nginx:1.25   "/docker-entrypoint.…"   2 hours ago   Up 2 hours   0.0.0.0:80->80/tcp   web
+d8a1f1e0c2b7   redis:7      "docker-entrypoint.s…"   3 days ago    Up 3 days    6379/tcp             cache
+`
+
+const dfMountedOnOutput = `Filesystem      Size  Used Avail Use% Mounted on
+devtmpfs        3.0G     0  3.0G   0% /dev
+tmpfs           5.9G     0  5.9G   0% /dev/shm
+/dev/vda         50G   20G   28G  42% /
+`
+
+func TestParseTable(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		text     string
+		opts     TableOptions
+		expected string
+		errors   string
+	}{
+		"whitespace": {
+			text:     psOutput,
+			expected: "[[PID TTY TIME CMD] [1 ? 00:00:02 /sbin/init splash] [812 pts/0 00:00:00 bash]]",
+		},
+		"whitespace empty": {
+			text:     "\n\n",
+			expected: "[]",
+		},
+		"fixed width": {
+			text:     "NAME    READY   STATUS\nweb-1   1/1     Running\ndb-0            Pending\n",
+			opts:     TableOptions{Split: SplitFixedWidth},
+			expected: "[[NAME READY STATUS] [web-1 1/1 Running] [db-0  Pending]]",
+		},
+		"fixed width skip lines": {
+			text:     "# generated\n" + dfOutput,
+			opts:     TableOptions{Split: SplitFixedWidth, SkipLines: 1},
+			expected: "[[Filesystem Size Used Avail Use% Mounted] [/dev/sda1 50G 20G 28G 42% /] [tmpfs 7.8G 0 7.8G 0% /dev/shm]]",
+		},
+		"fixed width docker ps": {
+			text: dockerPsOutput,
+			opts: TableOptions{Split: SplitFixedWidth},
+			expected: "[[CONTAINER ID IMAGE COMMAND CREATED STATUS PORTS NAMES] " +
+				"[4c01db0b339c nginx:1.25 \"/docker-entrypoint.…\" 2 hours ago Up 2 hours 0.0.0.0:80->80/tcp web] " +
+				"[d8a1f1e0c2b7 redis:7 \"docker-entrypoint.s…\" 3 days ago Up 3 days 6379/tcp cache]]",
+		},
+		"fixed width df": {
+			text: dfMountedOnOutput,
+			opts: TableOptions{Split: SplitFixedWidth},
+			expected: "[[Filesystem Size Used Avail Use% Mounted on] [devtmpfs 3.0G 0 3.0G 0% /dev] " +
+				"[tmpfs 5.9G 0 5.9G 0% /dev/shm] [/dev/vda 50G 20G 28G 42% /]]",
+		},
+		"whitespace df": {
+			text: dfMountedOnOutput,
+			expected: "[[Filesystem Size Used Avail Use% Mounted on] [devtmpfs 3.0G 0 3.0G 0% /dev] " +
+				"[tmpfs 5.9G 0 5.9G 0% /dev/shm] [/dev/vda 50G 20G 28G 42% /]]",
+		},
+		"fixed width right aligned overlap": {
+			text:     "  PID CMD\n12345 bash\n    7 init\n",
+			opts:     TableOptions{Split: SplitFixedWidth},
+			expected: "[[PID CMD] [12345 bash] [7 init]]",
+		},
+		"regexp": {
+			text:     dfOutput,
+			opts:     TableOptions{Split: SplitRegexp, Regexp: `^(?P<fs>/\S+)\s+\S+\s+\S+\s+\S+\s+(?P<use>\d+)%\s+(?P<mount>.*)$`},
+			expected: "[[fs use mount] [/dev/sda1 42 /]]",
+		},
+		"regexp no groups": {
+			text:   dfOutput,
+			opts:   TableOptions{Split: SplitRegexp, Regexp: `.*`},
+			errors: "no named groups",
+		},
+		"regexp bad": {
+			text:   dfOutput,
+			opts:   TableOptions{Split: SplitRegexp, Regexp: `(`},
+			errors: "missing closing )",
+		},
+		"delimiter": {
+			text:     "name | state\nweb | up\ndb | down\n",
+			opts:     TableOptions{Split: SplitDelimiter, Delimiter: "|"},
+			expected: "[[name state] [web up] [db down]]",
+		},
+		"delimiter missing": {
+			text:   "a,b",
+			opts:   TableOptions{Split: SplitDelimiter},
+			errors: "missing delimiter",
+		},
+		"unknown split": {
+			text:   "a,b",
+			opts:   TableOptions{Split: 99},
+			errors: "unknown table split",
+		},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseTable(plot.text, plot.opts)
+			if plot.errors != "" {
+				if err == nil || !strings.Contains(err.Error(), plot.errors) {
+					t.Errorf("expected error '%v', but got '%v'", plot.errors, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%v", actual) != plot.expected {
+				t.Errorf("expected '%v', but got '%v'", plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSbashTable(t *testing.T) {
+	t.Parallel()
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	rows, s := s.SbashTable(`printf 'NAME STATE\nweb up\ndb down\n'`, TableOptions{})
+	if s.IsFailed() {
+		t.Fatal(s.GetErr())
+	}
+	actual, err := rows.SelectColumnDistinctValues("STATE")
+	if err != nil || len(actual) != 2 {
+		t.Errorf("expected two states, got %v %v", actual, err)
+	}
+	rows, s = s.SbashTable("false", TableOptions{})
+	if !s.IsFailed() || rows != nil {
+		t.Errorf("expected failure from command")
+	}
+	s.CONTINUE("bad options")
+	_, s = s.SbashTable("true", TableOptions{Split: SplitRegexp, Regexp: "("})
+	if !s.IsFailed() {
+		t.Errorf("expected failure from regexp")
+	}
+}
@@ -1,8 +1,10 @@
 package dianella
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 )
 
@@ -64,6 +66,9 @@ func keysOfMap(m map[string]bool) []string {
 	}
 	return keys
 }
+
+// Rows2Maps - map each row keyed by the header, indexed by the key column values concatenated.
+// Keys can collide and later rows overwrite earlier ones, use Index for tuple keys.
 func (rows RowsOfFields) Rows2Maps(keys []string) (map[string]map[string]string, error) {
 	result := map[string]map[string]string{}
 	if len(rows) < 2 {
@@ -91,3 +96,83 @@ func (rows RowsOfFields) Rows2Maps(keys []string) (map[string]map[string]string,
 	}
 	return result, nil
 }
+
+// CSVOptions - controls how ReadCSVWith and ParseCSV read CSV, the zero value reads
+// comma separated records with a header row, like ReadCSV
+type CSVOptions struct {
+	Comma           rune // field delimiter, defaults to ','
+	Comment         rune // lines starting with this are ignored, zero for none
+	LazyQuotes      bool // allow quotes in unquoted fields and unescaped quotes in quoted fields
+	FieldsPerRecord int  // as csv.Reader: 0 - all like the first record, negative - ragged rows allowed
+	StripBOM        bool // remove a leading UTF-8 byte order mark
+	NoHeader        bool // the first row is data, header names column1, column2... are synthesized
+}
+
+// ReadCSVWith - read a CSV file with options, the filename "-" reads standard input
+func (s *Step) ReadCSVWith(filename string, opts CSVOptions) (Stepper, RowsOfFields) {
+	if s.Self.IsFailed() {
+		return s, nil
+	}
+	s.Self.Before("ReadCSVWith", filename)
+	defer s.Self.After()
+	var in io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			s.Self.FailErr(err)
+			return s, nil
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+	records, err := ParseCSV(in, opts)
+	if err != nil {
+		s.Self.FailErr(fmt.Errorf("%s: %w", filename, err))
+		return s, nil
+	}
+	return s, records
+}
+
+// ParseCSV - read all the CSV records from the reader with options
+func ParseCSV(in io.Reader, opts CSVOptions) (RowsOfFields, error) {
+	records, err := opts.newReader(in).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if !opts.NoHeader {
+		return records, nil
+	}
+	width := 0
+	for _, rec := range records {
+		width = intMax(width, len(rec))
+	}
+	return append(RowsOfFields{synthesizedHeader(width)}, records...), nil
+}
+
+// newReader - a csv.Reader configured by the options
+func (opts CSVOptions) newReader(in io.Reader) *csv.Reader {
+	if opts.StripBOM {
+		b := bufio.NewReader(in)
+		if r, _, err := b.ReadRune(); err == nil && r != '\uFEFF' {
+			_ = b.UnreadRune()
+		}
+		in = b
+	}
+	r := csv.NewReader(in)
+	if opts.Comma != 0 {
+		r.Comma = opts.Comma
+	}
+	r.Comment = opts.Comment
+	r.LazyQuotes = opts.LazyQuotes
+	r.FieldsPerRecord = opts.FieldsPerRecord
+	return r
+}
+
+// synthesizedHeader - column names column1, column2... for headerless files
+func synthesizedHeader(width int) []string {
+	header := make([]string, width)
+	for i := range header {
+		header[i] = fmt.Sprintf("column%d", i+1)
+	}
+	return header
+}
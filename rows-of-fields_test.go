@@ -181,3 +181,117 @@ func TestRowsOfFields_ReadCSV_Bad(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestParseCSV(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		given    string
+		opts     CSVOptions
+		expected string
+		errors   string
+	}{
+		"defaults": {
+			given:    "a,b\n1,2\n",
+			expected: "[[a b] [1 2]]",
+		},
+		"semicolon": {
+			given:    "a;b\n1;2\n",
+			opts:     CSVOptions{Comma: ';'},
+			expected: "[[a b] [1 2]]",
+		},
+		"comments": {
+			given:    "# exported\na,b\n1,2\n",
+			opts:     CSVOptions{Comment: '#'},
+			expected: "[[a b] [1 2]]",
+		},
+		"lazy quotes": {
+			given:    "a,b\n1,say \"hi\"\n",
+			opts:     CSVOptions{LazyQuotes: true},
+			expected: `[[a b] [1 say "hi"]]`,
+		},
+		"strict quotes": {
+			given:  "a,b\n1,say \"hi\"\n",
+			errors: "bare \" in non-quoted-field",
+		},
+		"ragged": {
+			given:    "a,b,c\n1,2\n3\n",
+			opts:     CSVOptions{FieldsPerRecord: -1},
+			expected: "[[a b c] [1 2] [3]]",
+		},
+		"not ragged": {
+			given:  "a,b,c\n1,2\n",
+			errors: "wrong number of fields",
+		},
+		"fixed fields": {
+			given:  "a,b\n1,2\n",
+			opts:   CSVOptions{FieldsPerRecord: 3},
+			errors: "wrong number of fields",
+		},
+		"bom stripped": {
+			given:    "\uFEFFa,b\n1,2\n",
+			opts:     CSVOptions{StripBOM: true},
+			expected: "[[a b] [1 2]]",
+		},
+		"bom kept": {
+			given:    "\uFEFFa,b\n",
+			expected: "[[\uFEFFa b]]",
+		},
+		"strip without bom": {
+			given:    "a,b\n",
+			opts:     CSVOptions{StripBOM: true},
+			expected: "[[a b]]",
+		},
+		"no header": {
+			given:    "1,2\n3\n",
+			opts:     CSVOptions{NoHeader: true, FieldsPerRecord: -1},
+			expected: "[[column1 column2] [1 2] [3]]",
+		},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseCSV(strings.NewReader(plot.given), plot.opts)
+			if plot.errors != "" {
+				if err == nil || !strings.Contains(err.Error(), plot.errors) {
+					t.Errorf("expected error '%v', but got '%v'", plot.errors, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%v", actual) != plot.expected {
+				t.Errorf("expected '%v', but got '%v'", plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRowsOfFields_ReadCSVWith_Ragged(t *testing.T) {
+	t.Parallel()
+	var s Stepper = BEGIN("Test CSV file reader").ContinueOnError(true)
+	s, actual := s.ReadCSVWith("test/badfixture.csv", CSVOptions{FieldsPerRecord: -1})
+	if s.IsFailed() {
+		t.Error(s.GetErr())
+	}
+	expected := `[[one two three four] [1 2 3] [5 6] [9]]`
+	if fmt.Sprintf("%v", actual) != expected {
+		t.Logf("expected '%v', but got '%v'", expected, actual)
+		t.Fail()
+	}
+	s, _ = s.ReadCSVWith("test/badfixture.csv", CSVOptions{})
+	if !s.IsFailed() || !strings.Contains(s.GetErr().Error(), "test/badfixture.csv") {
+		t.Errorf("expected failure naming the file, got %v", s.GetErr())
+	}
+}
+
+func TestRowsOfFields_ReadCSVWith_404(t *testing.T) {
+	t.Parallel()
+	var s Stepper = BEGIN("Test CSV file reader").ContinueOnError(true)
+	s, _ = s.ReadCSVWith("test/nosuchfile.csv", CSVOptions{})
+	t.Log(s.GetErr())
+	if !s.IsFailed() {
+		t.Fail()
+	}
+}
@@ -0,0 +1,194 @@
+package dianella
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Command - a command line for a Runner, with the templates already expanded
+type Command struct {
+	Line   string
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Runner - executes the commands of Bash and Sbash, see SetRunner. A failed command returns an
+// error, with an ExitCode() method if it ran and exited non-zero.
+type Runner interface {
+	Run(cmd Command) error
+}
+
+// bashWaitDelay - how long BashRunner waits for the output of background processes started by a
+// command which has exited
+const bashWaitDelay = 100 * time.Millisecond
+
+// BashRunner - the default Runner, executes commands with /bin/bash -c in Dir, or the current
+// directory if Dir is empty
+type BashRunner struct {
+	Dir string
+}
+
+// Run - run the command line with bash. Like a shell it does not wait for background processes,
+// such as "sleep 60 &", which still have the output open.
+func (r BashRunner) Run(cmd Command) error {
+	c := exec.Command("/bin/bash", "-c", cmd.Line)
+	c.Dir = r.Dir
+	c.Stdout = cmd.Stdout
+	c.Stderr = cmd.Stderr
+	c.WaitDelay = bashWaitDelay
+	err := c.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		return nil
+	}
+	return err
+}
+
+// WorkingDir - the directory commands run in
+func (r BashRunner) WorkingDir() string {
+	if r.Dir != "" {
+		return r.Dir
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// runnerDir - the directory the Runner runs commands in, if it has a WorkingDir method, otherwise
+// the current directory
+func runnerDir(r Runner) string {
+	if d, ok := r.(interface{ WorkingDir() string }); ok {
+		return d.WorkingDir()
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// closeRunner - close the Runner if it is an io.Closer, such as a RecordingRunner, as the run ends.
+// In dry-run mode no commands ran, so a cassette is not overwritten.
+func (s *Step) closeRunner() {
+	c, ok := s.Self.GetRunner().(io.Closer)
+	if !ok || s.dryRunSkip("close the runner") {
+		return
+	}
+	if err := c.Close(); err != nil {
+		s.logg.Print(s.Self.Redact(fmt.Sprintf("ERROR: closing the runner: %s", err)))
+	}
+}
+
+// SetRunner - execute the commands of Bash and Sbash with the runner, nil restores the BashRunner
+func (s *Step) SetRunner(r Runner) Stepper {
+	s.runner = r
+	return s
+}
+
+// GetRunner - the Runner executing the commands of Bash and Sbash
+func (s *Step) GetRunner() Runner {
+	if s.runner == nil {
+		return BashRunner{}
+	}
+	return s.runner
+}
+
+// ExitError - a command exited with a non-zero exit code
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
+
+// ExitCode - the exit code of the command
+func (e *ExitError) ExitCode() int { return e.Code }
+
+// FakeResponse - what a FakeRunner does for a matching command
+type FakeResponse struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+type fakeRule struct {
+	exact   string
+	pattern *regexp.Regexp
+	FakeResponse
+}
+
+// FakeRunner - a Runner for tests which answers commands with scripted responses instead of
+// running them, and records the command lines it was given
+type FakeRunner struct {
+	mu          sync.Mutex
+	rules       []fakeRule
+	invocations []string
+}
+
+// NewFakeRunner - a FakeRunner with no responses, every command fails until On or OnRegexp is used
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{}
+}
+
+// On - respond to the exact command line. The first matching rule wins.
+func (f *FakeRunner) On(line string, response FakeResponse) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeRule{exact: line, FakeResponse: response})
+	return f
+}
+
+// OnRegexp - respond to command lines matching the regular expression, panics if it does not
+// compile. The first matching rule wins.
+func (f *FakeRunner) OnRegexp(pattern string, response FakeResponse) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, fakeRule{pattern: regexp.MustCompile(pattern), FakeResponse: response})
+	return f
+}
+
+// Run - record the command and write the response of the first matching rule. Commands without
+// a rule fail.
+func (f *FakeRunner) Run(cmd Command) error {
+	f.mu.Lock()
+	f.invocations = append(f.invocations, cmd.Line)
+	var rule *fakeRule
+	for i := range f.rules {
+		r := &f.rules[i]
+		if (r.pattern == nil && r.exact == cmd.Line) || (r.pattern != nil && r.pattern.MatchString(cmd.Line)) {
+			rule = r
+			break
+		}
+	}
+	f.mu.Unlock()
+	if rule == nil {
+		return fmt.Errorf("fake runner has no response for command '%s'", cmd.Line)
+	}
+	if cmd.Stdout != nil {
+		_, _ = io.WriteString(cmd.Stdout, rule.Stdout)
+	}
+	if cmd.Stderr != nil {
+		_, _ = io.WriteString(cmd.Stderr, rule.Stderr)
+	}
+	if rule.ExitCode != 0 {
+		return &ExitError{Code: rule.ExitCode}
+	}
+	return nil
+}
+
+// Invocations - the command lines run so far, in order
+func (f *FakeRunner) Invocations() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.invocations...)
+}
+
+// Ran - true if the exact command line was run
+func (f *FakeRunner) Ran(line string) bool {
+	for _, invocation := range f.Invocations() {
+		if invocation == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package dianella
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFakeRunner(t *testing.T) {
+	t.Parallel()
+
+	testTable := map[string]struct {
+		cmd      string
+		stdout   string
+		exitCode int
+		failed   bool
+	}{
+		"exact":              {"kubectl get pods", "pod-1\npod-2\n", 0, false},
+		"template expanded":  {"deploy {{.Var.testName}}", "deployed\n", 0, false},
+		"regexp":             {"curl -fsS https://example.com/health", "ok", 0, false},
+		"scripted failure":   {"curl -fsS https://example.com/down", "", 7, true},
+		"scripted exit code": {"false", "", 1, true},
+		"unexpected command": {"rm -rf /", "", 0, true},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			fake := NewFakeRunner().
+				On("kubectl get pods", FakeResponse{Stdout: "pod-1\npod-2\n"}).
+				On("deploy template expanded", FakeResponse{Stdout: "deployed\n"}).
+				On("curl -fsS https://example.com/down", FakeResponse{Stderr: "connection refused", ExitCode: 7}).
+				OnRegexp(`^curl .*/health$`, FakeResponse{Stdout: "ok"}).
+				On("false", FakeResponse{ExitCode: 1})
+			var s Stepper = BEGIN(name).ContinueOnError(true)
+			s.SetRunner(fake).Set("testName", name)
+			out, _ := s.Sbash(plot.cmd)
+			if s.IsFailed() != plot.failed {
+				t.Errorf("expected failed %v, got %v", plot.failed, s.GetErr())
+			}
+			if out != plot.stdout {
+				t.Errorf("expected output '%s', got '%s'", plot.stdout, out)
+			}
+			history := s.History()
+			if code := history[len(history)-1].ExitCode; code != plot.exitCode {
+				t.Errorf("expected exit code %d, got %d", plot.exitCode, code)
+			}
+			expanded, _ := Expando(plot.cmd, s)
+			if !fake.Ran(expanded) || len(fake.Invocations()) != 1 {
+				t.Errorf("expected invocation '%s', got %v", expanded, fake.Invocations())
+			}
+		})
+	}
+}
+
+func TestFakeRunnerBash(t *testing.T) {
+	t.Parallel()
+	fake := NewFakeRunner().OnRegexp(".", FakeResponse{Stdout: "done\n", Stderr: "warning\n"})
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s.SetRunner(fake).
+		Bash("make build").
+		Bash("make test").
+		SetRunner(nil)
+	if s.IsFailed() {
+		t.Fatalf("unexpected failure %v", s.GetErr())
+	}
+	if !reflect.DeepEqual(fake.Invocations(), []string{"make build", "make test"}) {
+		t.Errorf("unexpected invocations %v", fake.Invocations())
+	}
+	if _, ok := s.GetRunner().(BashRunner); !ok {
+		t.Errorf("expected BashRunner after SetRunner(nil), got %T", s.GetRunner())
+	}
+}
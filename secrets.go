@@ -0,0 +1,193 @@
+package dianella
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// redactedMask - replaces secret values in logs and errors
+const redactedMask = "*****"
+
+// minSecretLength - shorter secret values are not masked, masking every "a" or "1" in the output
+// would make it unreadable and give the value away anyway
+const minSecretLength = 4
+
+// SetSecret - set a variable like Set but mark it as sensitive. Templates get the real value, but
+// it is masked in trace output, failure messages and the output of Bash. The value is not expanded
+// as a template.
+func (s *Step) SetSecret(name string, value any) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	if s.secrets == nil {
+		s.secrets = map[string]bool{}
+	}
+	s.secrets[name] = true
+	s.Var[name] = value
+	s.Self.Before("SetSecret", name, value)
+	defer s.Self.After()
+	return s
+}
+
+// IsSecret - true if the variable was set with SetSecret
+func (s *Step) IsSecret(name string) bool { return s.secrets[name] }
+
+// Redact - replace the values of secret variables in the text with a mask. Values shorter than
+// four characters are left alone.
+func (s *Step) Redact(text string) string {
+	var values []string
+	for name := range s.secrets {
+		v, ok := s.Var[name]
+		if !ok {
+			continue
+		}
+		if sv := fmt.Sprintf("%v", v); len(sv) >= minSecretLength {
+			values = append(values, sv)
+		}
+	}
+	// longest first so a secret containing another is masked whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		text = strings.ReplaceAll(text, v, redactedMask)
+	}
+	return text
+}
+
+// redactWriter - a line buffered writer which masks secrets before passing lines on
+type redactWriter struct {
+	w      io.Writer
+	redact func(string) string
+	buf    []byte
+}
+
+// redacting - wrap the writer so secrets are masked, unchanged if there are no secrets.
+// The returned function flushes any partial last line.
+func (s *Step) redacting(w io.Writer) (io.Writer, func()) {
+	if len(s.secrets) == 0 {
+		return w, func() {}
+	}
+	rw := &redactWriter{w: w, redact: s.Self.Redact}
+	return rw, rw.flush
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	rw.buf = append(rw.buf, p...)
+	i := bytes.LastIndexByte(rw.buf, '\n')
+	if i >= 0 {
+		_, err := io.WriteString(rw.w, rw.redact(string(rw.buf[:i+1])))
+		rw.buf = rw.buf[i+1:]
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (rw *redactWriter) flush() {
+	if len(rw.buf) > 0 {
+		_, _ = io.WriteString(rw.w, rw.redact(string(rw.buf)))
+		rw.buf = nil
+	}
+}
+
+// SecretProvider - resolves a secret reference, the part of "scheme://reference" after the scheme
+type SecretProvider interface {
+	Resolve(reference string) (string, error)
+}
+
+// SecretProviderFunc - adapts a function to the SecretProvider interface
+type SecretProviderFunc func(reference string) (string, error)
+
+// Resolve - call the function
+func (f SecretProviderFunc) Resolve(reference string) (string, error) { return f(reference) }
+
+// builtinSecretProviders - the schemes every Step can resolve
+var builtinSecretProviders = map[string]SecretProvider{
+	"file": SecretProviderFunc(func(path string) (string, error) {
+		data, err := os.ReadFile(path)
+		return strings.TrimRight(string(data), "\r\n"), err
+	}),
+	"env": SecretProviderFunc(func(name string) (string, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("missing environment variable '%s'", name)
+		}
+		return v, nil
+	}),
+}
+
+// commandSecretProvider - the built-in "cmd" scheme, runs the command with the Step's Runner like
+// Sbash, so it is faked, recorded and replayed, and logged instead of run in dry-run mode
+func (s *Step) commandSecretProvider() SecretProvider {
+	return SecretProviderFunc(func(command string) (string, error) {
+		if s.dryRunSkip("bash -c %s", command) {
+			return s.dryRunOutput, nil
+		}
+		var stdout bytes.Buffer
+		stderr, flushStderr := s.stderrWriter()
+		err := s.Self.GetRunner().Run(Command{Line: command, Stdout: &stdout, Stderr: stderr})
+		flushStderr()
+		return strings.TrimRight(stdout.String(), "\r\n"), err
+	})
+}
+
+// RegisterSecretProvider - resolve references with the scheme using the provider, for example
+// "vault" for "vault://secret/data/app#token". Replaces a built-in provider of the same scheme.
+func (s *Step) RegisterSecretProvider(scheme string, provider SecretProvider) Stepper {
+	if s.secretProviders == nil {
+		s.secretProviders = map[string]SecretProvider{}
+	}
+	s.secretProviders[scheme] = provider
+	return s
+}
+
+// SetFromSecret - resolve the reference through its provider and set the variable with SetSecret.
+// The reference is expanded as a template first. Built-in schemes are file:///path, env://NAME and
+// cmd://command. Resolved values are cached for the life of the Step.
+func (s *Step) SetFromSecret(name string, reference string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("SetFromSecret", name, reference)
+	defer s.Self.After()
+	ref, err := Expando(reference, s)
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	value, ok := s.secretCache[ref]
+	if !ok {
+		scheme, rest, found := strings.Cut(ref, "://")
+		if !found {
+			s.Self.FailErr(fmt.Errorf("expected scheme://reference, got '%s'", ref))
+			return s
+		}
+		provider, ok := s.secretProviders[scheme]
+		if !ok {
+			provider, ok = builtinSecretProviders[scheme]
+		}
+		if !ok && scheme == "cmd" {
+			provider, ok = s.commandSecretProvider(), true
+		}
+		if !ok {
+			s.Self.FailErr(fmt.Errorf("no secret provider for scheme '%s'", scheme))
+			return s
+		}
+		value, err = provider.Resolve(rest)
+		if err != nil {
+			s.Self.FailErr(fmt.Errorf("resolving %s: %w", ref, err))
+			return s
+		}
+		if s.secretCache == nil {
+			s.secretCache = map[string]string{}
+		}
+		if !s.Self.IsDryRun() {
+			s.secretCache[ref] = value
+		}
+	}
+	return s.Self.SetSecret(name, value)
+}
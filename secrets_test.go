@@ -0,0 +1,181 @@
+package dianella
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetSecret(t *testing.T) {
+	t.Parallel()
+	var b bytes.Buffer
+	s := BEGIN(t.Name()).ContinueOnError(true)
+	s.SetLogger(log.New(&b, "", 0))
+	s.Set("trace_length", 2000).
+		SetSecret("token", "xoxb-s3cr3t").
+		Set("header", "Authorization: Bearer {{.Var.token}}").
+		Bash("echo {{.Var.token}} >/dev/null").
+		Bash("false {{.Var.token}}").
+		CONTINUE("after failure")
+	if !strings.Contains(b.String(), "Bash.cmd         echo *****") {
+		t.Errorf("expected masked command in trace, got '%s'", b.String())
+	}
+	if strings.Contains(b.String(), "s3cr3t") {
+		t.Errorf("secret leaked into trace '%s'", b.String())
+	}
+	actual, _ := s.GetStringVar("header")
+	if actual != "Authorization: Bearer xoxb-s3cr3t" {
+		t.Errorf("expected templates to get the real value, got '%s'", actual)
+	}
+	if !s.IsSecret("token") || s.IsSecret("header") {
+		t.Errorf("expected only token to be secret")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+	s := BEGIN(t.Name()).ContinueOnError(true).
+		Set("trace", false).
+		Set("user", "bob").
+		SetSecret("password", "hunter2").
+		SetSecret("longer", "hunter2hunter2").
+		SetSecret("empty", "").
+		SetSecret("short", "e").
+		SetSecret("number", 31337)
+	for given, expected := range map[string]string{
+		"":                            "",
+		"bob":                         "bob",
+		"login bob:hunter2":           "login bob:*****",
+		"hunter2hunter2 and hunter2":  "***** and *****",
+		"port 31337":                  "port *****",
+		"nothing secret in this line": "nothing secret in this line",
+		"hello there":                 "hello there",
+	} {
+		if actual := s.Redact(given); actual != expected {
+			t.Errorf("Redact(%q) expected %q, got %q", given, expected, actual)
+		}
+	}
+}
+
+func TestRedactWriter(t *testing.T) {
+	t.Parallel()
+	s := BEGIN(t.Name())
+	s.Set("trace", false)
+	var b bytes.Buffer
+	w, flush := s.redacting(&b)
+	if w != &b {
+		t.Errorf("expected writer unchanged without secrets")
+	}
+	flush()
+
+	s.SetSecret("token", "abcdef")
+	w, flush = s.redacting(&b)
+	_, _ = w.Write([]byte("one abc"))
+	_, _ = w.Write([]byte("def two\nthree abc"))
+	if b.String() != "one ***** two\n" {
+		t.Errorf("expected whole lines masked, got %q", b.String())
+	}
+	_, _ = w.Write([]byte("def"))
+	flush()
+	if b.String() != "one ***** two\nthree *****" {
+		t.Errorf("expected flush to mask the partial line, got %q", b.String())
+	}
+}
+
+func TestSetFromSecret(t *testing.T) {
+	t.Setenv("DIANELLA_TEST_TOKEN", "from-env")
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "token"), []byte("from-file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	vault := SecretProviderFunc(func(reference string) (string, error) {
+		calls++
+		if reference == "secret/app#token" {
+			return "from-vault", nil
+		}
+		return "", fmt.Errorf("not found %s", reference)
+	})
+
+	testTable := map[string]struct {
+		reference string
+		expected  string
+		errors    string
+	}{
+		"file":            {reference: "file://{{.Var.dir}}/token", expected: "from-file"},
+		"file missing":    {reference: "file:///does/not/exist", errors: "no such file"},
+		"env":             {reference: "env://DIANELLA_TEST_TOKEN", expected: "from-env"},
+		"env missing":     {reference: "env://DIANELLA_TEST_NOPE", errors: "missing environment variable"},
+		"cmd":             {reference: "cmd://echo from-$((1+1))", expected: "from-2"},
+		"cmd fails":       {reference: "cmd://false", errors: "exit status 1"},
+		"registered":      {reference: "vault://secret/app#token", expected: "from-vault"},
+		"registered fail": {reference: "vault://nope", errors: "not found nope"},
+		"no scheme":       {reference: "token", errors: "expected scheme://reference"},
+		"unknown scheme":  {reference: "ssm://token", errors: "no secret provider for scheme 'ssm'"},
+		"bad template":    {reference: "file://{{", errors: "unclosed action"},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+			s.SetLogger(log.New(&b, "", 0))
+			s.Set("dir", dir).
+				RegisterSecretProvider("vault", vault).
+				SetFromSecret("token", plot.reference)
+			if plot.errors != "" {
+				if !s.IsFailed() || !strings.Contains(s.GetErr().Error(), plot.errors) {
+					t.Errorf("expected error '%v', but got '%v'", plot.errors, s.GetErr())
+				}
+				return
+			}
+			actual, s := s.GetStringVar("token")
+			if s.IsFailed() || actual != plot.expected || !s.IsSecret("token") {
+				t.Errorf("expected secret '%s', got '%s' %v", plot.expected, actual, s.GetErr())
+			}
+			if strings.Contains(b.String(), plot.expected) {
+				t.Errorf("secret leaked into trace '%s'", b.String())
+			}
+		})
+	}
+
+	calls = 0
+	s := BEGIN(t.Name()).ContinueOnError(true).
+		RegisterSecretProvider("vault", vault).
+		SetFromSecret("a", "vault://secret/app#token").
+		SetFromSecret("b", "vault://secret/app#token")
+	if s.IsFailed() || calls != 1 {
+		t.Errorf("expected the second lookup to be cached, provider called %d times", calls)
+	}
+}
+
+func TestSetFromSecretCommandRunner(t *testing.T) {
+	t.Parallel()
+	var b bytes.Buffer
+	fake := NewFakeRunner().On("vault read -field=token app", FakeResponse{Stdout: "from-fake\n"})
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s.SetLogger(log.New(&b, "", 0))
+	s.Set("trace", false).
+		SetRunner(fake).
+		SetFromSecret("token", "cmd://vault read -field=token app")
+	actual, s := s.GetStringVar("token")
+	if s.IsFailed() || actual != "from-fake" || !fake.Ran("vault read -field=token app") {
+		t.Errorf("expected the secret from the runner, got '%s' %v", actual, s.GetErr())
+	}
+
+	s.DryRun(true).
+		DryRunOutput("placeholder").
+		SetFromSecret("other", "cmd://vault read -field=other app")
+	actual, s = s.GetStringVar("other")
+	if s.IsFailed() || actual != "placeholder" || len(fake.Invocations()) != 1 {
+		t.Errorf("expected dry-run not to run the command, got '%s' %v", actual, fake.Invocations())
+	}
+	if !strings.Contains(b.String(), "DRY-RUN: bash -c vault read -field=other app") {
+		t.Errorf("expected the command to be logged, got '%s'", b.String())
+	}
+}
@@ -0,0 +1,214 @@
+package dianella
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadJSON - decode the JSON file into the variable as generic maps, slices and values
+func (s *Step) ReadJSON(filename string, variableName string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("ReadJSON", filename, variableName)
+	defer s.Self.After()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	value, err := decodeJSON(data)
+	if err != nil {
+		s.Self.FailErr(fmt.Errorf("%s: %w", filename, err))
+		return s
+	}
+	s.Var[variableName] = value
+	return s
+}
+
+// ReadYAML - decode the YAML file into the variable as generic maps, slices and values
+func (s *Step) ReadYAML(filename string, variableName string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("ReadYAML", filename, variableName)
+	defer s.Self.After()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	var value any
+	err = yaml.Unmarshal(data, &value)
+	if err != nil {
+		s.Self.FailErr(fmt.Errorf("%s: %w", filename, err))
+		return s
+	}
+	s.Var[variableName] = value
+	return s
+}
+
+// SbashJSON - run the command like Sbash and decode its JSON output into the variable
+func (s *Step) SbashJSON(cmd string, variableName string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("SbashJSON", cmd, variableName)
+	defer s.Self.After()
+	out, _ := s.Self.Sbash(cmd)
+	if s.Self.IsFailed() {
+		return s
+	}
+	value, err := decodeJSON([]byte(out))
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	s.Var[variableName] = value
+	return s
+}
+
+// decodeJSON - decode a single JSON value keeping numbers as json.Number so they print as written
+func decodeJSON(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var value any
+	err := d.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+// Query - select part of decoded JSON or YAML data with a path such as "spec.replicas",
+// "items[0].name" or "items[*].name". A "[*]" maps the rest of the path over a list.
+// It is also available in templates: {{query .Var.cfg "spec.replicas"}}
+func Query(data any, path string) (any, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return data, nil
+	}
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			open := strings.Index(part, "[")
+			if open == -1 {
+				segments = append(segments, part)
+				break
+			}
+			if open > 0 {
+				segments = append(segments, part[:open])
+			}
+			end := strings.Index(part, "]")
+			if end < open {
+				return nil, fmt.Errorf("missing ] in query %q", path)
+			}
+			segments = append(segments, part[open:end+1])
+			part = part[end+1:]
+		}
+	}
+	return querySegments(data, segments, path)
+}
+
+func querySegments(data any, segments []string, path string) (any, error) {
+	if len(segments) == 0 {
+		return data, nil
+	}
+	segment, rest := segments[0], segments[1:]
+	if segment == "[*]" {
+		list, ok := data.([]any)
+		if !ok {
+			return nil, fmt.Errorf("query %q: [*] of %T", path, data)
+		}
+		result := make([]any, len(list))
+		for i, item := range list {
+			v, err := querySegments(item, rest, path)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+		return result, nil
+	}
+	switch node := data.(type) {
+	case map[string]any:
+		v, ok := node[segment]
+		if !ok {
+			return nil, fmt.Errorf("query %q: missing key %q", path, segment)
+		}
+		return querySegments(v, rest, path)
+	case []any:
+		i, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(segment, "["), "]"))
+		if err != nil {
+			return nil, fmt.Errorf("query %q: index %q of list", path, segment)
+		}
+		if i < 0 {
+			i += len(node)
+		}
+		if i < 0 || i >= len(node) {
+			return nil, fmt.Errorf("query %q: index %s out of range %d", path, segment, len(node))
+		}
+		return querySegments(node[i], rest, path)
+	}
+	return nil, fmt.Errorf("query %q: %q of %T", path, segment, data)
+}
+
+// ToRowsOfFields - convert a list of objects into rows, the header is the sorted union of the
+// keys. Nested values are written as JSON.
+func ToRowsOfFields(data any) (RowsOfFields, error) {
+	list, ok := data.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected list of objects, got %T", data)
+	}
+	columns := map[string]bool{}
+	for i, item := range list {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected object at index %d, got %T", i, item)
+		}
+		for k := range object {
+			columns[k] = true
+		}
+	}
+	header := keysOfMap(columns)
+	sort.Strings(header)
+	rows := RowsOfFields{header}
+	for _, item := range list {
+		object := item.(map[string]any)
+		row := make([]string, len(header))
+		for c, h := range header {
+			cell, err := cellText(object[h])
+			if err != nil {
+				return nil, err
+			}
+			row[c] = cell
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// cellText - scalars as text, missing values empty, lists and objects as JSON
+func cellText(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return x, nil
+	case map[string]any, []any:
+		b, err := json.Marshal(x)
+		return string(b), err
+	}
+	return fmt.Sprintf("%v", v), nil
+}
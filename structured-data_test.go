@@ -0,0 +1,130 @@
+package dianella
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONAndYAML(t *testing.T) {
+	t.Parallel()
+
+	for name, read := range map[string]func(Stepper) Stepper{
+		"json":  func(s Stepper) Stepper { return s.ReadJSON("test/fixture.json", "cfg") },
+		"yaml":  func(s Stepper) Stepper { return s.ReadYAML("test/fixture.yaml", "cfg") },
+		"sbash": func(s Stepper) Stepper { return s.SbashJSON("cat test/fixture.json", "cfg") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+			s = read(s)
+			actual, s := s.Sexpand(`{{query .Var.cfg "metadata.name"}} {{query .Var.cfg "spec.replicas"}} {{query .Var.cfg "spec.containers[*].name"}} {{query .Var.cfg "spec.containers[0].ports[-1]"}}`)
+			if s.IsFailed() {
+				t.Fatal(s.GetErr())
+			}
+			if actual != "web 3 [nginx sidecar] 443" {
+				t.Errorf("expected 'web 3 [nginx sidecar] 443', got '%s'", actual)
+			}
+			containers, err := Query(s.GetVar()["cfg"], "spec.containers")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := ToRowsOfFields(containers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%v", rows) != "[[image name ports] [nginx:1.25 nginx [80,443]] [envoy:1.28 sidecar ]]" {
+				t.Errorf("unexpected rows %v", rows)
+			}
+		})
+	}
+}
+
+func TestReadJSONFailures(t *testing.T) {
+	t.Parallel()
+
+	for name, read := range map[string]func(Stepper) Stepper{
+		"json missing file":   func(s Stepper) Stepper { return s.ReadJSON("test/nosuchfile.json", "cfg") },
+		"json bad":            func(s Stepper) Stepper { return s.ReadJSON("test/fixture.yaml", "cfg") },
+		"yaml missing file":   func(s Stepper) Stepper { return s.ReadYAML("test/nosuchfile.yaml", "cfg") },
+		"yaml bad":            func(s Stepper) Stepper { return s.ReadYAML("test/badfixture.yaml", "cfg") },
+		"sbash command fails": func(s Stepper) Stepper { return s.SbashJSON("false", "cfg") },
+		"sbash not json":      func(s Stepper) Stepper { return s.SbashJSON("echo hello", "cfg") },
+		"sbash trailing data": func(s Stepper) Stepper { return s.SbashJSON("echo '{} {}'", "cfg") },
+		"query in template":   func(s Stepper) Stepper { return s.Set("x", `{{query .Var "nope"}}`) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+			s = read(s)
+			t.Log(s.GetErr())
+			if !s.IsFailed() {
+				t.Errorf("expected failure")
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+	data := map[string]any{
+		"items": []any{
+			map[string]any{"name": "a", "tags": []any{"x", "y"}},
+			map[string]any{"name": "b", "tags": []any{}},
+		},
+		"count": 2,
+	}
+
+	testTable := map[string]struct {
+		path     string
+		expected string
+		errors   string
+	}{
+		"root":           {path: "", expected: fmt.Sprintf("%v", data)},
+		"dollar":         {path: "$.count", expected: "2"},
+		"key":            {path: "count", expected: "2"},
+		"index":          {path: "items[1].name", expected: "b"},
+		"dotted index":   {path: "items.0.name", expected: "a"},
+		"nested index":   {path: "items[0].tags[1]", expected: "y"},
+		"negative index": {path: "items[-1].name", expected: "b"},
+		"wildcard":       {path: "items[*].name", expected: "[a b]"},
+		"missing key":    {path: "items[0].zzz", errors: `missing key "zzz"`},
+		"out of range":   {path: "items[2]", errors: "out of range"},
+		"bad index":      {path: "items[x]", errors: "index"},
+		"missing ]":      {path: "items[0", errors: "missing ]"},
+		"scalar":         {path: "count.x", errors: `"x" of int`},
+		"wildcard map":   {path: "[*]", errors: "[*] of map"},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			actual, err := Query(data, plot.path)
+			if plot.errors != "" {
+				if err == nil || !strings.Contains(err.Error(), plot.errors) {
+					t.Errorf("expected error '%v', but got '%v'", plot.errors, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprintf("%v", actual) != plot.expected {
+				t.Errorf("expected '%v', but got '%v'", plot.expected, actual)
+			}
+		})
+	}
+}
+
+func TestToRowsOfFields(t *testing.T) {
+	t.Parallel()
+	_, err := ToRowsOfFields(map[string]any{})
+	if err == nil {
+		t.Errorf("expected error for object")
+	}
+	_, err = ToRowsOfFields([]any{"x"})
+	if err == nil {
+		t.Errorf("expected error for list of strings")
+	}
+	actual, err := ToRowsOfFields([]any{})
+	if err != nil || fmt.Sprintf("%v", actual) != "[[]]" {
+		t.Errorf("expected empty header, got %v %v", actual, err)
+	}
+}
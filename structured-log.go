@@ -0,0 +1,61 @@
+package dianella
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// NewTextLogHandler - an slog handler writing key=value lines, for SetLogHandler
+func NewTextLogHandler(w io.Writer, level slog.Level) slog.Handler {
+	return slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})
+}
+
+// NewJSONLogHandler - an slog handler writing JSON lines, for SetLogHandler
+func NewJSONLogHandler(w io.Writer, level slog.Level) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+}
+
+// SetLogHandler - replace the printf tracing with structured records. Each step method logs
+// a debug record when it starts and an info record, or an error record if the step failed,
+// when it ends with the duration, status and error. The "trace" variable is not used.
+// A nil handler restores the printf tracing.
+func (s *Step) SetLogHandler(h slog.Handler) Stepper {
+	s.handler = h
+	return s
+}
+
+// beginRecord - log the start of a step method
+func (s *Step) beginRecord(call traceCall) {
+	s.emit(slog.LevelDebug, call.method, call.method, slog.Any("args", call.args))
+}
+
+// endRecord - log the end of a step method
+func (s *Step) endRecord(r StepRecord) {
+	attrs := []slog.Attr{
+		slog.Any("args", r.Args),
+		slog.Duration("duration", r.Duration),
+		slog.Int("status", r.Status),
+	}
+	level := slog.LevelInfo
+	if r.Error != "" {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", r.Error))
+		if r.ExitCode > 0 {
+			attrs = append(attrs, slog.Int("exit_code", r.ExitCode))
+		}
+	}
+	s.emit(level, r.Method+" done", r.Method, attrs...)
+}
+
+func (s *Step) emit(level slog.Level, msg string, method string, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if !s.handler.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.AddAttrs(slog.String("step", s.Self.Redact(s.description)), slog.String("method", method))
+	r.AddAttrs(attrs...)
+	_ = s.handler.Handle(ctx, r)
+}
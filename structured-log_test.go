@@ -0,0 +1,66 @@
+package dianella
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSetLogHandler(t *testing.T) {
+	t.Parallel()
+	var b bytes.Buffer
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s.SetLogHandler(NewJSONLogHandler(&b, slog.LevelDebug)).
+		SetSecret("token", "s3cr3t").
+		AND("run commands").
+		Bash("echo {{.Var.token}} >/dev/null").
+		Bash("exit 3")
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var r map[string]any
+		err := json.Unmarshal([]byte(line), &r)
+		if err != nil {
+			t.Fatalf("bad JSON record %s: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	if strings.Contains(b.String(), "s3cr3t") {
+		t.Errorf("secret leaked into log '%s'", b.String())
+	}
+	last := records[len(records)-1]
+	if last["msg"] != "Bash done" || last["level"] != "ERROR" || last["exit_code"] != 3.0 ||
+		last["status"] != 1.0 || last["step"] != "run commands" || last["error"] != "exit status 3" {
+		t.Errorf("unexpected last record %v", last)
+	}
+	if _, ok := last["duration"]; !ok {
+		t.Errorf("expected duration in %v", last)
+	}
+	var methods []string
+	for _, r := range records {
+		methods = append(methods, r["msg"].(string))
+	}
+	expected := "SetSecret,SetSecret done,AND,AND done,Bash,Bash.cmd,Bash done,Bash,FailErr,FailErr done,Bash done"
+	if strings.Join(methods, ",") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(methods, ","))
+	}
+}
+
+func TestSetLogHandlerLevel(t *testing.T) {
+	t.Parallel()
+	var b bytes.Buffer
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s.SetLogHandler(NewTextLogHandler(&b, slog.LevelInfo)).
+		Set("x", "1")
+	if strings.Contains(b.String(), "level=DEBUG") || !strings.Contains(b.String(), `level=INFO msg="Set done"`) {
+		t.Errorf("expected only info records, got '%s'", b.String())
+	}
+
+	b.Reset()
+	s.SetLogHandler(nil).Set("trace", false).Set("y", "2")
+	if b.Len() != 0 {
+		t.Errorf("expected no records after removing the handler, got '%s'", b.String())
+	}
+}
@@ -0,0 +1,331 @@
+package dianella
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// targetsFlag - the standard flag listing the targets instead of running them
+var targetsFlag = flag.Bool("dianella.targets", false, "list the targets of Run and their dependencies instead of running them")
+
+// defaultTargetState - the file recording the input hashes of targets, see TargetState
+const defaultTargetState = ".dianella-targets.json"
+
+// target - a named body of steps with dependencies, like a Makefile rule
+type target struct {
+	name    string
+	deps    []string
+	body    func(Stepper) Stepper
+	inputs  []string
+	outputs []string
+}
+
+// Target - declare a target for Run, with the targets it depends on and the body of steps which
+// makes it. Follow with Inputs and Outputs to skip the target when it is up to date.
+func (s *Step) Target(name string, deps []string, body func(Stepper) Stepper) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("Target", name, strings.Join(deps, " "))
+	defer s.Self.After()
+	if s.findTarget(name) != nil {
+		s.Self.FailErr(fmt.Errorf("target '%s' is already declared", name))
+		return s
+	}
+	s.targets = append(s.targets, &target{name: name, deps: deps, body: body})
+	return s
+}
+
+// Inputs - the glob patterns of the files the last declared Target reads
+func (s *Step) Inputs(globs ...string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("Inputs", strings.Join(globs, " "))
+	defer s.Self.After()
+	if len(s.targets) == 0 {
+		s.Self.FailErr(fmt.Errorf("Inputs before any Target"))
+		return s
+	}
+	t := s.targets[len(s.targets)-1]
+	t.inputs = append(t.inputs, globs...)
+	return s
+}
+
+// Outputs - the glob patterns of the files the last declared Target writes. A target without
+// inputs or outputs always runs.
+func (s *Step) Outputs(globs ...string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("Outputs", strings.Join(globs, " "))
+	defer s.Self.After()
+	if len(s.targets) == 0 {
+		s.Self.FailErr(fmt.Errorf("Outputs before any Target"))
+		return s
+	}
+	t := s.targets[len(s.targets)-1]
+	t.outputs = append(t.outputs, globs...)
+	return s
+}
+
+// TargetState - the file where Run records the hashes of the inputs of targets, by default
+// ".dianella-targets.json" in the current directory
+func (s *Step) TargetState(filename string) Stepper {
+	s.targetState = filename
+	return s
+}
+
+// Run - run the named targets, or all of them if none are named, after their dependencies. A
+// target is skipped when all its outputs exist and are newer than its inputs, or when the hash of
+// its inputs is unchanged since it last ran. Fails on unknown targets and dependency cycles. With
+// the -dianella.targets flag the targets are listed instead, and in dry-run mode the targets which
+// would run are logged without running their bodies.
+func (s *Step) Run(targets ...string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("Run", strings.Join(targets, " "))
+	defer s.Self.After()
+	if *targetsFlag {
+		s.writeTargets(os.Stdout)
+		return s
+	}
+	if len(targets) == 0 {
+		for _, t := range s.targets {
+			targets = append(targets, t.name)
+		}
+	}
+	order, err := s.targetOrder(targets)
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	state, err := s.readTargetState()
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	for _, t := range order {
+		upToDate, hash, err := t.upToDate(state[t.name])
+		if err != nil {
+			s.Self.FailErr(err)
+			return s
+		}
+		if upToDate {
+			s.Self.Before("Run.uptodate", t.name)
+		} else {
+			s.Self.Before("Run.target", t.name)
+			if s.dryRunSkip("run target %s", t.name) {
+				continue
+			}
+			t.body(s.Self)
+			if s.Self.IsFailed() {
+				return s
+			}
+			if len(t.inputs) > 0 && len(t.outputs) > 0 {
+				hash, err = t.inputHash()
+				if err != nil {
+					s.Self.FailErr(err)
+					return s
+				}
+			}
+		}
+		if hash == "" || hash == state[t.name] || s.Self.IsDryRun() {
+			continue
+		}
+		state[t.name] = hash
+		if err := s.writeTargetState(state); err != nil {
+			s.Self.FailErr(err)
+			return s
+		}
+	}
+	return s
+}
+
+func (s *Step) findTarget(name string) *target {
+	for _, t := range s.targets {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// targetOrder - the targets and their dependencies, each after its dependencies
+func (s *Step) targetOrder(names []string) ([]*target, error) {
+	var order []*target
+	done := map[string]bool{}
+	var path []string
+	var visit func(name string, from string) error
+	visit = func(name string, from string) error {
+		if done[name] {
+			return nil
+		}
+		for i, p := range path {
+			if p == name {
+				return fmt.Errorf("target cycle %s -> %s", strings.Join(path[i:], " -> "), name)
+			}
+		}
+		t := s.findTarget(name)
+		if t == nil && from == "" {
+			return fmt.Errorf("unknown target '%s'", name)
+		}
+		if t == nil {
+			return fmt.Errorf("unknown target '%s', a dependency of '%s'", name, from)
+		}
+		path = append(path, name)
+		for _, dep := range t.deps {
+			if err := visit(dep, name); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		done[name] = true
+		order = append(order, t)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, ""); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// upToDate - true if the outputs exist and are newer than the inputs or the inputs have the
+// recorded hash. Also returns the hash of the inputs, empty when the target has none to hash. A
+// target without inputs is never up to date, there is nothing to say its outputs are stale.
+func (t *target) upToDate(recorded string) (bool, string, error) {
+	outputs, err := globFiles(t.outputs, true)
+	if err != nil || len(outputs) == 0 {
+		return false, "", err
+	}
+	inputs, err := globFiles(t.inputs, false)
+	if err != nil || len(inputs) == 0 {
+		return false, "", nil
+	}
+	hash, err := t.inputHash()
+	if err != nil {
+		return false, "", err
+	}
+	newestInput, oldestOutput := time.Time{}, time.Time{}
+	for _, info := range inputs {
+		if info.ModTime().After(newestInput) {
+			newestInput = info.ModTime()
+		}
+	}
+	for _, info := range outputs {
+		if oldestOutput.IsZero() || info.ModTime().Before(oldestOutput) {
+			oldestOutput = info.ModTime()
+		}
+	}
+	return oldestOutput.After(newestInput) || hash == recorded, hash, nil
+}
+
+// inputHash - the SHA-256 of the names and contents of the input files
+func (t *target) inputHash() (string, error) {
+	inputs, err := globFiles(t.inputs, false)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", name)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// globFiles - the files matching the patterns. With all, nil if any pattern matches nothing.
+func globFiles(patterns []string, all bool) (map[string]fs.FileInfo, error) {
+	files := map[string]fs.FileInfo{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 && all {
+			return nil, nil
+		}
+		for _, name := range matches {
+			info, err := os.Stat(name)
+			if err != nil {
+				return nil, err
+			}
+			files[name] = info
+		}
+	}
+	return files, nil
+}
+
+func (s *Step) targetStateFile() string {
+	if s.targetState == "" {
+		return defaultTargetState
+	}
+	return s.targetState
+}
+
+func (s *Step) readTargetState() (map[string]string, error) {
+	state := map[string]string{}
+	p, err := os.ReadFile(s.targetStateFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(p, &state); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.targetStateFile(), err)
+	}
+	return state, nil
+}
+
+func (s *Step) writeTargetState(state map[string]string) error {
+	p, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.targetStateFile(), append(p, '\n'), 0644)
+}
+
+// writeTargets - list the targets like Makefile rules, with their inputs and outputs
+func (s *Step) writeTargets(w io.Writer) {
+	for _, t := range s.targets {
+		fmt.Fprintf(w, "%s:", t.name)
+		for _, dep := range t.deps {
+			fmt.Fprintf(w, " %s", dep)
+		}
+		fmt.Fprintln(w)
+		if len(t.inputs) > 0 {
+			fmt.Fprintf(w, "\tinputs: %s\n", strings.Join(t.inputs, " "))
+		}
+		if len(t.outputs) > 0 {
+			fmt.Fprintf(w, "\toutputs: %s\n", strings.Join(t.outputs, " "))
+		}
+	}
+}
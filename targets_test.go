@@ -0,0 +1,168 @@
+package dianella
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTargets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	gen := filepath.Join(dir, "gen.txt")
+	out := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(src, []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var ran []string
+	body := func(name string, command string) func(Stepper) Stepper {
+		return func(s Stepper) Stepper {
+			ran = append(ran, name)
+			return s.Bash(command)
+		}
+	}
+	s := BEGIN(t.Name()).ContinueOnError(true).
+		Set("trace", false).
+		Set("dir", dir).
+		TargetState(filepath.Join(dir, "state.json")).
+		Target("build", []string{"generate"}, body("build", "cp {{.Var.dir}}/gen.txt {{.Var.dir}}/out.txt")).
+		Inputs(gen).
+		Outputs(out).
+		Target("generate", nil, body("generate", "tr a-z A-Z < {{.Var.dir}}/src.txt > {{.Var.dir}}/gen.txt")).
+		Inputs(filepath.Join(dir, "src*")).
+		Outputs(gen).
+		Target("check", []string{"build", "generate"}, body("check", "test -s {{.Var.dir}}/out.txt"))
+
+	later := time.Now().Add(time.Hour)
+	testTable := []struct {
+		name     string
+		before   func() error
+		expected []string
+	}{
+		{"first run", func() error { return nil }, []string{"generate", "build", "check"}},
+		{"up to date", func() error { return nil }, []string{"check"}},
+		{"touched", func() error { return os.Chtimes(src, later, later) }, []string{"check"}},
+		{"changed", func() error { return os.WriteFile(src, []byte("two"), 0644) }, []string{"generate", "build", "check"}},
+	}
+	for _, plot := range testTable {
+		if err := plot.before(); err != nil {
+			t.Fatal(err)
+		}
+		ran = nil
+		s.Run("check")
+		if s.IsFailed() {
+			t.Fatalf("%s: unexpected failure %v", plot.name, s.GetErr())
+		}
+		if !reflect.DeepEqual(ran, plot.expected) {
+			t.Errorf("%s: expected %v to run, got %v", plot.name, plot.expected, ran)
+		}
+	}
+	p, _ := os.ReadFile(out)
+	if string(p) != "TWO" {
+		t.Errorf("unexpected output '%s'", p)
+	}
+}
+
+func TestRunTargetFailures(t *testing.T) {
+	t.Parallel()
+	nothing := func(s Stepper) Stepper { return s }
+
+	testTable := map[string]struct {
+		declare  func(s Stepper) Stepper
+		run      []string
+		expected string
+	}{
+		"cycle": {func(s Stepper) Stepper {
+			return s.Target("a", []string{"b"}, nothing).Target("b", []string{"c"}, nothing).Target("c", []string{"a"}, nothing)
+		}, []string{"a"}, "target cycle a -> b -> c -> a"},
+		"self":        {func(s Stepper) Stepper { return s.Target("a", []string{"a"}, nothing) }, nil, "target cycle a -> a"},
+		"unknown":     {func(s Stepper) Stepper { return s.Target("a", nil, nothing) }, []string{"b"}, "unknown target 'b'"},
+		"unknown dep": {func(s Stepper) Stepper { return s.Target("a", []string{"b"}, nothing) }, nil, "unknown target 'b', a dependency of 'a'"},
+		"duplicate":   {func(s Stepper) Stepper { return s.Target("a", nil, nothing).Target("a", nil, nothing) }, nil, "target 'a' is already declared"},
+		"no target":   {func(s Stepper) Stepper { return s.Inputs("*.go") }, nil, "Inputs before any Target"},
+		"body fails": {func(s Stepper) Stepper {
+			return s.Target("a", nil, func(s Stepper) Stepper { return s.Fail("broken") })
+		}, nil, "broken"},
+		"bad glob": {func(s Stepper) Stepper { return s.Target("a", nil, nothing).Outputs("[") }, nil, "syntax error in pattern"},
+	}
+
+	for name, plot := range testTable {
+		name, plot := name, plot
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := BEGIN(name).ContinueOnError(true).Set("trace", false).TargetState(filepath.Join(t.TempDir(), "state.json"))
+			plot.declare(s).Run(plot.run...)
+			if s.GetErr() == nil || s.GetErr().Error() != plot.expected {
+				t.Errorf("expected '%s', got '%v'", plot.expected, s.GetErr())
+			}
+		})
+	}
+}
+
+func TestWriteTargets(t *testing.T) {
+	t.Parallel()
+	nothing := func(s Stepper) Stepper { return s }
+	s := &Step{}
+	s.Init(s, t.Name())
+	s.Set("trace", false).
+		Target("build", []string{"generate", "vet"}, nothing).
+		Inputs("*.go", "go.mod").
+		Outputs("bin/app").
+		Target("generate", nil, nothing)
+	var b bytes.Buffer
+	s.writeTargets(&b)
+	expected := "build: generate vet\n\tinputs: *.go go.mod\n\toutputs: bin/app\ngenerate:\n"
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+}
+
+func TestRunTargetsWithoutInputs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	runs := 0
+	s := BEGIN(t.Name()).ContinueOnError(true).
+		Set("trace", false).
+		TargetState(filepath.Join(dir, "state.json")).
+		Target("stamp", nil, func(s Stepper) Stepper {
+			runs++
+			return s.Bash("date > " + out)
+		}).
+		Outputs(out)
+	s.Run().Run()
+	if s.IsFailed() || runs != 2 {
+		t.Errorf("expected a target without inputs to run every time, ran %d times %v", runs, s.GetErr())
+	}
+}
+
+func TestRunTargetsDryRun(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	var b bytes.Buffer
+	ran := false
+	s := BEGIN(t.Name()).ContinueOnError(true).
+		Set("trace", false).
+		TargetState(filepath.Join(dir, "state.json")).
+		Target("build", nil, func(s Stepper) Stepper {
+			ran = true
+			return s
+		})
+	s.SetLogger(log.New(&b, "", 0))
+	s.DryRun(true).Run("build")
+	if s.IsFailed() || ran {
+		t.Errorf("expected the body not to run in dry-run mode, ran %v %v", ran, s.GetErr())
+	}
+	if !strings.Contains(b.String(), "DRY-RUN: run target build") {
+		t.Errorf("expected the target in '%s'", b.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state.json")); err == nil {
+		t.Errorf("expected no target state in dry-run mode")
+	}
+}
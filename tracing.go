@@ -0,0 +1,224 @@
+package dianella
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Tracer - opens a span for each step method, see SetTracer. The steps which run a body of steps,
+// Call, WithDelims and Run, are the only blocks, and the spans of the steps in the body are
+// children of their span.
+type Tracer interface {
+	StartSpan(parent Span, name string, start time.Time) Span
+}
+
+// Span - one traced step method
+type Span interface {
+	SetAttribute(key string, value any)
+	End(err error, end time.Time)
+}
+
+// SetTracer - open a span in Before and close it in After for every step method. The spans carry
+// the step description, arguments, expanded command, status, exit code and error. A nil tracer
+// stops tracing.
+func (s *Step) SetTracer(t Tracer) Stepper {
+	s.tracer = t
+	return s
+}
+
+// startSpan - open the span for a call, nested in the innermost call in progress
+func (s *Step) startSpan(call *traceCall) {
+	var parent Span
+	for i := len(s.calls) - 1; i >= 0 && parent == nil; i-- {
+		parent = s.calls[i].span
+	}
+	call.span = s.tracer.StartSpan(parent, call.method, call.start)
+	call.span.SetAttribute("dianella.step", s.Self.Redact(s.description))
+	for i, arg := range call.args {
+		call.span.SetAttribute("dianella.arg."+strconv.Itoa(i), arg)
+	}
+}
+
+// spanAttribute - add an attribute to the span of the innermost call in progress, if traced
+func (s *Step) spanAttribute(key string, value any) {
+	if len(s.calls) > 0 && s.calls[len(s.calls)-1].span != nil {
+		s.calls[len(s.calls)-1].span.SetAttribute(key, value)
+	}
+}
+
+// endSpan - close the span for a call with the outcome
+func endSpan(span Span, r StepRecord) {
+	span.SetAttribute("dianella.status", r.Status)
+	if r.ExitCode != 0 {
+		span.SetAttribute("process.exit_code", r.ExitCode)
+	}
+	var err error
+	if r.Error != "" {
+		err = errorString(r.Error)
+	}
+	span.End(err, r.Start.Add(r.Duration))
+}
+
+// errorString - an error from already redacted text
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
+
+// OTLPFileTracer - a Tracer collecting spans in memory and writing them in the OTLP JSON
+// format, so traces can be inspected or imported offline
+type OTLPFileTracer struct {
+	serviceName string
+	traceID     string
+	mu          sync.Mutex
+	spans       []*otlpSpan
+}
+
+// NewOTLPFileTracer - a tracer for one trace of the named service
+func NewOTLPFileTracer(serviceName string) *OTLPFileTracer {
+	return &OTLPFileTracer{serviceName: serviceName, traceID: randomHex(16)}
+}
+
+type otlpSpan struct {
+	tracer     *OTLPFileTracer
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]any
+	err        error
+}
+
+// StartSpan - begin a span, parent may be nil for a root span
+func (t *OTLPFileTracer) StartSpan(parent Span, name string, start time.Time) Span {
+	span := &otlpSpan{tracer: t, spanID: randomHex(8), name: name, start: start, attributes: map[string]any{}}
+	if p, ok := parent.(*otlpSpan); ok {
+		span.parentID = p.spanID
+	}
+	return span
+}
+
+// SetAttribute - add an attribute to the span
+func (span *otlpSpan) SetAttribute(key string, value any) {
+	span.attributes[key] = value
+}
+
+// End - finish the span, it is exported by WriteFile
+func (span *otlpSpan) End(err error, end time.Time) {
+	span.err = err
+	span.end = end
+	span.tracer.mu.Lock()
+	defer span.tracer.mu.Unlock()
+	span.tracer.spans = append(span.tracer.spans, span)
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpanJSON struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// JSON - the finished spans as an OTLP ExportTraceServiceRequest in JSON
+func (t *OTLPFileTracer) JSON() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]otlpSpanJSON, 0, len(t.spans))
+	for _, span := range t.spans {
+		js := otlpSpanJSON{
+			TraceID:           t.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attributes),
+			Status:            otlpStatus{Code: 1}, // STATUS_CODE_OK
+		}
+		if span.err != nil {
+			js.Status = otlpStatus{Code: 2, Message: span.err.Error()} // STATUS_CODE_ERROR
+		}
+		spans = append(spans, js)
+	}
+	request := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": t.serviceName}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/birchb1024/dianella"},
+				"spans": spans,
+			}},
+		}},
+	}
+	return json.MarshalIndent(request, "", "  ")
+}
+
+// WriteFile - write the finished spans to the file in OTLP JSON
+func (t *OTLPFileTracer) WriteFile(filename string) error {
+	data, err := t.JSON()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// otlpAttributes - attributes in key order, integers as OTLP intValue and the rest as strings
+func otlpAttributes(attributes map[string]any) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]otlpAttribute, 0, len(keys))
+	for _, k := range keys {
+		var v otlpValue
+		switch x := attributes[k].(type) {
+		case int:
+			i := strconv.Itoa(x)
+			v.IntValue = &i
+		case string:
+			v.StringValue = &x
+		default:
+			str, _ := json.Marshal(x)
+			text := string(str)
+			v.StringValue = &text
+		}
+		result = append(result, otlpAttribute{Key: k, Value: v})
+	}
+	return result
+}
+
+// randomHex - n random bytes in hex for trace and span ids
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
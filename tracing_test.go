@@ -0,0 +1,144 @@
+package dianella
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type otlpTestSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes"`
+	Status       otlpStatus      `json:"status"`
+}
+
+func (span otlpTestSpan) attribute(key string) string {
+	for _, a := range span.Attributes {
+		if a.Key != key {
+			continue
+		}
+		if a.Value.IntValue != nil {
+			return *a.Value.IntValue
+		}
+		return *a.Value.StringValue
+	}
+	return ""
+}
+
+func TestSetTracer(t *testing.T) {
+	t.Parallel()
+	tracer := NewOTLPFileTracer("dianella-test")
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s.SetTracer(tracer).
+		SetSecret("token", "s3cr3t").
+		AND("nested").
+		Call(func(s Stepper) Stepper {
+			return s.Bash("echo {{.Var.token}} >/dev/null")
+		}).
+		AND("fails").
+		Bash("exit 3")
+
+	filename := filepath.Join(t.TempDir(), "trace.json")
+	err := tracer.WriteFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cr3t") {
+		t.Errorf("secret leaked into trace %s", data)
+	}
+	var request struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpAttribute `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []otlpTestSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *request.ResourceSpans[0].Resource.Attributes[0].Value.StringValue != "dianella-test" {
+		t.Errorf("expected service name in %v", request.ResourceSpans[0].Resource)
+	}
+	spans := map[string][]otlpTestSpan{}
+	var names []string
+	for _, span := range request.ResourceSpans[0].ScopeSpans[0].Spans {
+		spans[span.Name] = append(spans[span.Name], span)
+		names = append(names, span.Name)
+		if len(span.TraceID) != 32 || len(span.SpanID) != 16 {
+			t.Errorf("bad ids in %v", span)
+		}
+	}
+	expected := "SetSecret,AND,Bash,Call,AND,FailErr,Bash"
+	if strings.Join(names, ",") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(names, ","))
+	}
+
+	call, nested := spans["Call"][0], spans["Bash"][0]
+	if nested.ParentSpanID != call.SpanID || call.ParentSpanID != "" {
+		t.Errorf("expected Bash span inside Call, got %v and %v", nested, call)
+	}
+	if nested.attribute("dianella.command") != "echo ***** >/dev/null" ||
+		nested.attribute("process.exit_code") != "0" || nested.Status.Code != 1 {
+		t.Errorf("unexpected nested span %v", nested)
+	}
+
+	failed := spans["Bash"][1]
+	if failed.attribute("dianella.step") != "fails" || failed.attribute("process.exit_code") != "3" ||
+		failed.Status.Code != 2 || failed.Status.Message != "exit status 3" {
+		t.Errorf("unexpected failed span %v", failed)
+	}
+	if spans["FailErr"][0].ParentSpanID != failed.SpanID {
+		t.Errorf("expected FailErr span inside Bash, got %v", spans["FailErr"][0])
+	}
+}
+
+func TestSetTracerBlocks(t *testing.T) {
+	t.Parallel()
+	tracer := NewOTLPFileTracer("dianella-test")
+	var s Stepper = BEGIN(t.Name()).ContinueOnError(true)
+	s.Set("trace", false).
+		SetTracer(tracer).
+		WithDelims("<<", ">>", func(s Stepper) Stepper { return s.Set("a", "<<.Var.trace>>") }).
+		Target("build", nil, func(s Stepper) Stepper { return s.Set("b", "1") }).
+		Run("build")
+
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpTestSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	data, err := tracer.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatal(err)
+	}
+	parents := map[string]string{}
+	ids := map[string]string{}
+	for _, span := range request.ResourceSpans[0].ScopeSpans[0].Spans {
+		name := span.Name + ":" + span.attribute("dianella.arg.0")
+		ids[span.SpanID] = name
+		parents[name] = span.ParentSpanID
+	}
+	for child, parent := range map[string]string{"Set:a": "WithDelims:<<", "Set:b": "Run:build"} {
+		if ids[parents[child]] != parent {
+			t.Errorf("expected %s inside %s, got %v", child, parent, ids[parents[child]])
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package dianella
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// VarsOptions - controls how LoadVarsWith merges a file into Var
+type VarsOptions struct {
+	Namespace string // if set the keys are loaded into a map in this variable instead of Var itself
+	Prefix    string // prepended to each key name
+	EnvPrefix string // if set, environment variables named EnvPrefix + KEY override the file
+	NoFlags   bool   // do not override from command-line flags of the same name
+}
+
+// LoadVars - load variables from a .env, .json, .yaml, .yml or .toml file chosen by extension.
+// Command-line flags set with the same name as a key override the file.
+func (s *Step) LoadVars(path string) Stepper {
+	return s.Self.LoadVarsWith(path, VarsOptions{})
+}
+
+// LoadVarsWith - LoadVars with a namespace, key prefix and control of overrides. The precedence
+// is file, then environment variables with the EnvPrefix, then command-line flags.
+func (s *Step) LoadVarsWith(path string, opts VarsOptions) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("LoadVars", path)
+	defer s.Self.After()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.Self.FailErr(err)
+		return s
+	}
+	values, err := decodeVars(varsFormat(path), data)
+	if err != nil {
+		s.Self.FailErr(fmt.Errorf("%s: %w", path, err))
+		return s
+	}
+	setFlags := map[string]string{}
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = f.Value.String() })
+	target := s.Var
+	if opts.Namespace != "" {
+		ns, ok := s.Var[opts.Namespace].(map[string]any)
+		if !ok {
+			ns = map[string]any{}
+			s.Var[opts.Namespace] = ns
+		}
+		target = ns
+	}
+	for k, v := range values {
+		if env, ok := os.LookupEnv(envName(opts.EnvPrefix + k)); ok && opts.EnvPrefix != "" {
+			v = env
+		}
+		if f, ok := setFlags[k]; ok && !opts.NoFlags {
+			v = f
+		}
+		target[opts.Prefix+k] = v
+	}
+	return s
+}
+
+// SaveVars - write the named variables to a file in the format chosen by the extension, so a
+// later run can LoadVars them
+func (s *Step) SaveVars(path string, names ...string) Stepper {
+	if s.Self.IsFailed() {
+		return s
+	}
+	s.Self.Before("SaveVars", path, names)
+	defer s.Self.After()
+	values := map[string]any{}
+	for _, name := range names {
+		v, ok := s.Var[name]
+		if !ok {
+			s.Self.FailErr(fmt.Errorf("missing '%s' variable", name))
+			return s
+		}
+		values[name] = v
+	}
+	data, err := encodeVars(varsFormat(path), values)
+	if err != nil {
+		s.Self.FailErr(fmt.Errorf("%s: %w", path, err))
+		return s
+	}
+	if s.dryRunSkip("write %s:\n%s", path, data) {
+		return s
+	}
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		s.Self.FailErr(err)
+	}
+	return s
+}
+
+// varsFormat - the file format from the extension, ".env" files may also be named ".env.local" etc.
+func varsFormat(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".env" || strings.HasPrefix(filepath.Base(path), ".env") {
+		return ".env"
+	}
+	if ext == ".yml" {
+		return ".yaml"
+	}
+	return ext
+}
+
+func decodeVars(format string, data []byte) (map[string]any, error) {
+	values := map[string]any{}
+	var err error
+	switch format {
+	case ".env":
+		return parseDotEnv(data)
+	case ".json":
+		v, err := decodeJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		object, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected object, got %T", v)
+		}
+		return object, nil
+	case ".yaml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unknown variables file format '%s'", format)
+	}
+	return values, err
+}
+
+func encodeVars(format string, values map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case ".env":
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value, err := dotEnvQuote(fmt.Sprintf("%v", values[k]))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			buf.WriteString(fmt.Sprintf("%s=%s\n", k, value))
+		}
+	case ".json":
+		e := json.NewEncoder(&buf)
+		e.SetIndent("", "  ")
+		err := e.Encode(values)
+		if err != nil {
+			return nil, err
+		}
+	case ".yaml":
+		return yaml.Marshal(values)
+	case ".toml":
+		err := toml.NewEncoder(&buf).Encode(values)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown variables file format '%s'", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// dotEnvQuote - quote a value so parseDotEnv reads it back unchanged. Values with a '$' are
+// single quoted, which are not interpolated, so they cannot also have a quote or a newline.
+func dotEnvQuote(value string) (string, error) {
+	if !strings.Contains(value, "$") {
+		return strconv.Quote(value), nil
+	}
+	if strings.ContainsAny(value, "'\n") {
+		return "", fmt.Errorf("cannot write a value with '$' and a quote or newline to a .env file")
+	}
+	return "'" + value + "'", nil
+}
+
+var (
+	dotEnvLine   = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_.]*)\s*=\s*(.*?)\s*$`)
+	dotEnvVar    = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+	envNameChars = regexp.MustCompile(`[^A-Z0-9_]`)
+)
+
+// parseDotEnv - read KEY=value lines. Single quoted values are literal, double quoted and bare
+// values have ${VAR} and $VAR replaced from earlier keys or the environment.
+func parseDotEnv(data []byte) (map[string]any, error) {
+	values := map[string]any{}
+	lookup := func(name string) string {
+		if v, ok := values[name]; ok {
+			return v.(string)
+		}
+		return os.Getenv(name)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		m := dotEnvLine.FindStringSubmatch(text)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: expected KEY=value: %s", line, text)
+		}
+		key, value := m[1], m[2]
+		switch {
+		case strings.HasPrefix(value, "'"):
+			if len(value) < 2 || !strings.HasSuffix(value, "'") {
+				return nil, fmt.Errorf("line %d: unterminated quote: %s", line, text)
+			}
+			values[key] = value[1 : len(value)-1]
+			continue
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w: %s", line, err, text)
+			}
+			value = unquoted
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		values[key] = dotEnvVar.ReplaceAllStringFunc(value, func(ref string) string {
+			name := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(ref, "$"), "{"), "}")
+			return lookup(name)
+		})
+	}
+	return values, scanner.Err()
+}
+
+// envName - the environment variable name for a key, upper case with other characters as '_'
+func envName(key string) string {
+	return envNameChars.ReplaceAllString(strings.ToUpper(key), "_")
+}
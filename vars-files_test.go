@@ -0,0 +1,150 @@
+package dianella
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadVars(t *testing.T) {
+	t.Setenv("DIANELLA_TEST_HOME", "/home/test")
+	t.Setenv("APP_REGION", "ap-southeast-2")
+
+	testTable := map[string]struct {
+		filename string
+		content  string
+		opts     VarsOptions
+		expected string
+		errors   string
+	}{
+		"dotenv": {
+			filename: ".env",
+			content: `# comment
+export NAME=web
+DIR=${DIANELLA_TEST_HOME}/$NAME   # trailing comment
+LITERAL='${NAME} stays'
+QUOTED="line\tone ${NAME}"
+`,
+			expected: "map[DIR:/home/test/web LITERAL:${NAME} stays NAME:web QUOTED:line\tone web]",
+		},
+		"dotenv bad line": {
+			filename: "bad.env",
+			content:  "NAME\n",
+			errors:   "line 1: expected KEY=value",
+		},
+		"dotenv bad quote": {
+			filename: "bad.env",
+			content:  "A='x\n",
+			errors:   "line 1: unterminated quote",
+		},
+		"json": {
+			filename: "vars.json",
+			content:  `{"name": "web", "replicas": 3}`,
+			expected: "map[name:web replicas:3]",
+		},
+		"json not object": {
+			filename: "vars.json",
+			content:  `[1]`,
+			errors:   "expected object",
+		},
+		"yaml": {
+			filename: "vars.yml",
+			content:  "name: web\nreplicas: 3\n",
+			expected: "map[name:web replicas:3]",
+		},
+		"toml": {
+			filename: "vars.toml",
+			content:  "name = \"web\"\nreplicas = 3\n",
+			expected: "map[name:web replicas:3]",
+		},
+		"toml bad": {
+			filename: "vars.toml",
+			content:  "name = \n",
+			errors:   "vars.toml",
+		},
+		"unknown format": {
+			filename: "vars.ini",
+			content:  "[x]",
+			errors:   "unknown variables file format '.ini'",
+		},
+		"namespace and prefix": {
+			filename: "vars.json",
+			content:  `{"name": "web"}`,
+			opts:     VarsOptions{Namespace: "cfg", Prefix: "app_"},
+			expected: "map[cfg:map[app_name:web]]",
+		},
+		"env override": {
+			filename: "vars.yaml",
+			content:  "region: us-east-1\nname: web\n",
+			opts:     VarsOptions{EnvPrefix: "APP_"},
+			expected: "map[name:web region:ap-southeast-2]",
+		},
+	}
+
+	for name, plot := range testTable {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), plot.filename)
+			err := os.WriteFile(path, []byte(plot.content), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := BEGIN(t.Name()).ContinueOnError(true).
+				Set("trace", false).
+				LoadVarsWith(path, plot.opts)
+			if plot.errors != "" {
+				if !s.IsFailed() || !strings.Contains(s.GetErr().Error(), plot.errors) {
+					t.Errorf("expected error '%v', but got '%v'", plot.errors, s.GetErr())
+				}
+				return
+			}
+			if s.IsFailed() {
+				t.Fatal(s.GetErr())
+			}
+			delete(s.GetVar(), "trace")
+			if fmt.Sprintf("%v", s.GetVar()) != plot.expected {
+				t.Errorf("expected '%v', but got '%v'", plot.expected, s.GetVar())
+			}
+		})
+	}
+}
+
+func TestSaveVars(t *testing.T) {
+	t.Parallel()
+
+	for _, filename := range []string{"saved.env", "saved.json", "saved.yaml", "saved.toml"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+			s := BEGIN(t.Name()).ContinueOnError(true).
+				Set("name", "web").
+				Set("quote", `say "hi"`).
+				Set("price", "$5 ${HOME} $name").
+				Set("other", "not saved").
+				SaveVars(path, "name", "quote", "price")
+			loaded := BEGIN(t.Name()).ContinueOnError(true).LoadVarsWith(path, VarsOptions{Namespace: "loaded"})
+			if s.IsFailed() || loaded.IsFailed() {
+				t.Fatal(s.GetErr(), loaded.GetErr())
+			}
+			actual := fmt.Sprintf("%v", loaded.GetVar()["loaded"])
+			if actual != `map[name:web price:$5 ${HOME} $name quote:say "hi"]` {
+				t.Errorf("unexpected round trip %s", actual)
+			}
+		})
+	}
+
+	s := BEGIN(t.Name()).ContinueOnError(true).SaveVars(filepath.Join(t.TempDir(), "x.json"), "nope")
+	if !s.IsFailed() || !strings.Contains(s.GetErr().Error(), "missing 'nope' variable") {
+		t.Errorf("expected missing variable, got %v", s.GetErr())
+	}
+	for _, filename := range []string{"x.ini", "vars"} {
+		s = BEGIN(t.Name()).ContinueOnError(true).Set("a", "b").SaveVars(filepath.Join(t.TempDir(), filename), "a")
+		if !s.IsFailed() || !strings.Contains(s.GetErr().Error(), "unknown variables file format") {
+			t.Errorf("expected unknown format failure for %s, got %v", filename, s.GetErr())
+		}
+	}
+	s = BEGIN(t.Name()).ContinueOnError(true).Set("a", "it's $5").SaveVars(filepath.Join(t.TempDir(), "x.env"), "a")
+	if !s.IsFailed() || !strings.Contains(s.GetErr().Error(), "cannot write a value with '$'") {
+		t.Errorf("expected a value which cannot be quoted to fail, got %v", s.GetErr())
+	}
+}